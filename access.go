@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Access modes of a bot, set with access_mode.
+const (
+	AccessModeOpen      = "open"      // Everyone may talk to the bot (default)
+	AccessModeAllowlist = "allowlist" // Only users added with /allow
+	AccessModeInvite    = "invite"    // Users added with /allow or who redeemed an invite code
+)
+
+// Lists an AccessEntry can be on.
+const (
+	accessAllow = "allow"
+	accessDeny  = "deny"
+)
+
+// errInvalidInvite is returned for unknown, expired and used-up invite codes.
+var errInvalidInvite = errors.New("invalid invite code")
+
+// accessEntry returns the allowlist or denylist entry of a user, if any.
+func (b *Bot) accessEntry(userID int64) (AccessEntry, bool) {
+	var entry AccessEntry
+	err := b.db.Where("bot_id = ? AND user_id = ?", b.botID, userID).First(&entry).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			ErrorLogger.Printf("Error checking access of user %d: %v", userID, err)
+		}
+		return AccessEntry{}, false
+	}
+	return entry, true
+}
+
+// startCode returns the payload of a "/start <code>" deep link message.
+func startCode(message *models.Message) (string, bool) {
+	parts := strings.Fields(message.Text)
+	if len(parts) != 2 || (parts[0] != "/start" && !strings.HasPrefix(parts[0], "/start@")) {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// checkAccess reports whether a message may be processed. Denylisted users are dropped silently
+// in every mode. In allowlist and invite-only modes only the owner, co-owners, holders of
+// user:access and allowlisted users get through; others are told how to get access in private
+// chats and ignored in groups. In invite-only mode "/start <code>" redeems an invite code.
+func (b *Bot) checkAccess(ctx context.Context, message *models.Message) bool {
+	userID := message.From.ID
+	entry, listed := b.accessEntry(userID)
+	if listed && entry.List == accessDeny {
+		InfoLogger.Printf("[%s] Ignoring message from denylisted user %d", b.config.ID, userID)
+		return false
+	}
+
+	mode := b.config.AccessMode
+	if mode == "" || mode == AccessModeOpen {
+		return true
+	}
+	allowed := listed || b.isCoOwner(userID) || b.hasScope(userID, ScopeUserAccess)
+	private := message.Chat.Type == models.ChatTypePrivate || message.Chat.ID == userID
+
+	if code, ok := startCode(message); ok && mode == AccessModeInvite {
+		switch {
+		case allowed:
+			b.notify(ctx, message.Chat.ID, "You already have access to this bot.")
+		case b.redeemInvite(code, userID) != nil:
+			b.notify(ctx, message.Chat.ID, "This invite code is invalid, expired or used up.")
+		default:
+			InfoLogger.Printf("[%s] User %d joined with an invite code", b.config.ID, userID)
+			b.notify(ctx, message.Chat.ID, "✅ Welcome! You now have access to this bot.")
+		}
+		return false
+	}
+	if allowed {
+		return true
+	}
+
+	InfoLogger.Printf("[%s] Ignoring message from user %d without access", b.config.ID, userID)
+	if private {
+		if mode == AccessModeInvite {
+			b.notify(ctx, message.Chat.ID, "This bot is invite-only. Open your invite link, or send /start <code> with your invite code.")
+		} else {
+			b.notify(ctx, message.Chat.ID, "This bot is private. Ask its owner for access.")
+		}
+	}
+	return false
+}
+
+// redeemInvite uses up one use of an invite code and adds the user to the allowlist.
+func (b *Bot) redeemInvite(code string, userID int64) error {
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		var invite InviteCode
+		if err := tx.Where("bot_id = ? AND code = ?", b.botID, code).First(&invite).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errInvalidInvite
+			}
+			return err
+		}
+		if invite.ExpiresAt != nil && !b.clock.Now().Before(*invite.ExpiresAt) {
+			return errInvalidInvite
+		}
+		// Guarded by uses < max_uses so concurrent redemptions can't exceed the limit.
+		result := tx.Model(&InviteCode{}).Where("id = ? AND uses < max_uses", invite.ID).
+			Update("uses", gorm.Expr("uses + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInvalidInvite
+		}
+		return upsertAccessEntry(tx, AccessEntry{BotID: b.botID, UserID: userID, List: accessAllow, AddedBy: invite.CreatedBy})
+	})
+}
+
+// upsertAccessEntry adds a user to a list, moving them off the other list.
+func upsertAccessEntry(db *gorm.DB, entry AccessEntry) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "bot_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "list", "added_by"}),
+	}).Create(&entry).Error
+}
+
+// handleAllowCommand handles /allow <user_id|@username>.
+func (b *Bot) handleAllowCommand(ctx context.Context, c *commandCall) {
+	b.addAccessEntry(c, accessAllow)
+}
+
+// handleDenyCommand handles /deny <user_id|@username>.
+func (b *Bot) handleDenyCommand(ctx context.Context, c *commandCall) {
+	b.addAccessEntry(c, accessDeny)
+}
+
+// handleDisallowCommand handles /disallow <user_id|@username>.
+func (b *Bot) handleDisallowCommand(ctx context.Context, c *commandCall) {
+	b.removeAccessEntry(c, accessAllow)
+}
+
+// handleUndenyCommand handles /undeny <user_id|@username>.
+func (b *Bot) handleUndenyCommand(ctx context.Context, c *commandCall) {
+	b.removeAccessEntry(c, accessDeny)
+}
+
+// addAccessEntry puts the user named in the call on list, moving them off the other list.
+func (b *Bot) addAccessEntry(c *commandCall, list string) {
+	userID := c.userID()
+
+	target, err := b.resolveUser(c.str(userRefArg.name))
+	if err != nil {
+		c.reply(fmt.Sprintf("❌ %v", err))
+		return
+	}
+	if list == accessDeny && (target.TelegramID == userID || b.actsAsOwner(target)) {
+		c.reply("You can't deny yourself, the owner or a co-owner.")
+		return
+	}
+
+	if err := upsertAccessEntry(b.db, AccessEntry{BotID: b.botID, UserID: target.TelegramID, List: list, AddedBy: userID}); err != nil {
+		ErrorLogger.Printf("Error adding user %d to the %slist: %v", target.TelegramID, list, err)
+		c.reply("Sorry, I couldn't update the access lists.")
+		return
+	}
+	InfoLogger.Printf("[%s] User %d added user %d to the %slist", b.config.ID, userID, target.TelegramID, list)
+	if list == accessDeny {
+		c.reply(fmt.Sprintf("🚫 %s is on the denylist; their messages are ignored.", userLabel(target)))
+		return
+	}
+	c.reply(fmt.Sprintf("✅ %s is on the allowlist.", userLabel(target)))
+}
+
+// removeAccessEntry takes the user named in the call off list.
+func (b *Bot) removeAccessEntry(c *commandCall, list string) {
+	userID := c.userID()
+
+	target, err := b.resolveUser(c.str(userRefArg.name))
+	if err != nil {
+		c.reply(fmt.Sprintf("❌ %v", err))
+		return
+	}
+
+	result := b.db.Where("bot_id = ? AND user_id = ? AND list = ?", b.botID, target.TelegramID, list).Delete(&AccessEntry{})
+	if result.Error != nil {
+		ErrorLogger.Printf("Error removing user %d from the %slist: %v", target.TelegramID, list, result.Error)
+		c.reply("Sorry, I couldn't update the access lists.")
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.reply(fmt.Sprintf("%s isn't on the %slist.", userLabel(target), list))
+		return
+	}
+	InfoLogger.Printf("[%s] User %d removed user %d from the %slist", b.config.ID, userID, target.TelegramID, list)
+	c.reply(fmt.Sprintf("✅ %s is no longer on the %slist.", userLabel(target), list))
+}
+
+// handleInviteCommand handles /invite [uses] [expiry]: it creates an invite code for invite-only
+// mode and replies with its deep link. Codes are single-use unless uses says otherwise.
+func (b *Bot) handleInviteCommand(ctx context.Context, c *commandCall) {
+	userID := c.userID()
+
+	invite := InviteCode{BotID: b.botID, Code: rand.Text(), MaxUses: 1, CreatedBy: userID}
+	if c.has("uses") {
+		if c.int("uses") < 1 {
+			c.reply("The number of uses must be a positive number.")
+			return
+		}
+		invite.MaxUses = int(c.int("uses"))
+	}
+	if c.has("expiry") {
+		duration, err := parseBanDuration(c.str("expiry"))
+		if err != nil {
+			c.reply(fmt.Sprintf("Invalid expiry: %v.", err))
+			return
+		}
+		if duration != nil {
+			expires := b.clock.Now().Add(*duration)
+			invite.ExpiresAt = &expires
+		}
+	}
+
+	if err := b.db.Create(&invite).Error; err != nil {
+		ErrorLogger.Printf("Error creating invite code: %v", err)
+		c.reply("Sorry, I couldn't create the invite.")
+		return
+	}
+	InfoLogger.Printf("[%s] User %d created an invite code for %d use(s)", b.config.ID, userID, invite.MaxUses)
+
+	text := fmt.Sprintf("🎟 Invite code for %d use(s): %s", invite.MaxUses, invite.Code)
+	if me, err := b.botUser(ctx); err == nil && me.Username != "" {
+		text += fmt.Sprintf("\nLink: https://t.me/%s?start=%s", me.Username, invite.Code)
+	}
+	if invite.ExpiresAt != nil {
+		text += fmt.Sprintf("\nExpires: %s", invite.ExpiresAt.Format(timeLayout))
+	}
+	if b.config.AccessMode != AccessModeInvite {
+		text += "\nNote: codes are only accepted while access_mode is \"invite\"."
+	}
+	c.reply(text)
+}
+
+// handleAccessCommand shows the access mode, the allow and deny lists and the usable invite codes.
+func (b *Bot) handleAccessCommand(ctx context.Context, c *commandCall) {
+	mode := b.config.AccessMode
+	if mode == "" {
+		mode = AccessModeOpen
+	}
+	var entries []AccessEntry
+	if err := b.db.Where("bot_id = ?", b.botID).Order("user_id").Limit(2 * maxListedUsers).Find(&entries).Error; err != nil {
+		ErrorLogger.Printf("Error listing access entries: %v", err)
+		c.reply("Sorry, I couldn't load the access settings.")
+		return
+	}
+	var invites []InviteCode
+	if err := b.db.Where("bot_id = ? AND uses < max_uses AND (expires_at IS NULL OR expires_at > ?)", b.botID, b.clock.Now()).
+		Order("id").Limit(maxListedUsers).Find(&invites).Error; err != nil {
+		ErrorLogger.Printf("Error listing invite codes: %v", err)
+		c.reply("Sorry, I couldn't load the access settings.")
+		return
+	}
+
+	lists := map[string][]string{}
+	for _, e := range entries {
+		lists[e.List] = append(lists[e.List], strconv.FormatInt(e.UserID, 10))
+	}
+	text := fmt.Sprintf("🔐 Access mode: %s", mode)
+	for _, list := range []string{accessAllow, accessDeny} {
+		ids := lists[list]
+		if len(ids) == 0 {
+			ids = []string{"none"}
+		}
+		text += fmt.Sprintf("\n%slist: %s", strings.ToUpper(list[:1])+list[1:], strings.Join(ids, ", "))
+	}
+	if len(invites) > 0 {
+		text += "\nInvite codes:"
+		for _, invite := range invites {
+			text += fmt.Sprintf("\n- %s (%d of %d used)", invite.Code, invite.Uses, invite.MaxUses)
+		}
+	}
+	c.reply(text)
+}
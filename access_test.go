@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestAccessModes verifies invite-only and allowlist access, invite codes and the denylist.
+func TestAccessModes(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	b.config.AccessMode = AccessModeInvite
+	sent := make(map[int64]string)
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		sent[params.ChatID.(int64)] = params.Text
+		return &models.Message{}, nil
+	}
+	var llmCalls int
+	b.llm = &MockLLMProvider{CompleteFunc: func(context.Context, CompletionRequest) (CompletionResponse, error) {
+		llmCalls++
+		return CompletionResponse{Text: "reply"}, nil
+	}}
+	send := func(userID int64, text string) {
+		b.handleUpdate(context.Background(), nil, commandUpdate(userID, userID, text))
+	}
+
+	// Strangers are turned away without storing or answering their messages.
+	send(789, "hello")
+	assert.Zero(t, llmCalls)
+	assert.Contains(t, sent[789], "invite-only")
+	var stored int64
+	b.db.Model(&Message{}).Where("user_id = ?", 789).Count(&stored)
+	assert.Zero(t, stored)
+
+	send(123, "/invite 2 1d")
+	assert.Contains(t, sent[123], "🎟 Invite code for 2 use(s): ")
+	assert.Contains(t, sent[123], "https://t.me/test_bot?start=")
+	var invite InviteCode
+	assert.NoError(t, b.db.Where("bot_id = ?", b.botID).First(&invite).Error)
+
+	send(789, "/start wrong")
+	assert.Contains(t, sent[789], "invalid, expired or used up")
+	send(789, "/start "+invite.Code)
+	assert.Contains(t, sent[789], "Welcome")
+	send(789, "hello")
+	assert.Equal(t, 1, llmCalls)
+	send(789, "/start "+invite.Code)
+	assert.Equal(t, "You already have access to this bot.", sent[789])
+
+	// The second use works; a third does not.
+	send(790, "/start "+invite.Code)
+	assert.Contains(t, sent[790], "Welcome")
+	send(791, "/start "+invite.Code)
+	assert.Contains(t, sent[791], "used up")
+
+	// Codes expire.
+	send(123, "/invite 1 1h")
+	var second InviteCode
+	assert.NoError(t, b.db.Where("bot_id = ? AND id <> ?", b.botID, invite.ID).First(&second).Error)
+	b.clock.(*MockClock).Advance(time.Hour)
+	send(791, "/start "+second.Code)
+	assert.Contains(t, sent[791], "expired")
+
+	// Allowlist mode ignores codes; /allow and /disallow manage access.
+	b.config.AccessMode = AccessModeAllowlist
+	send(791, "hello")
+	assert.Equal(t, "This bot is private. Ask its owner for access.", sent[791])
+	send(789, "/allow 791")
+	assert.Contains(t, sent[789], "Permission denied", "regular users can't manage access")
+	send(123, "/allow 791")
+	assert.Equal(t, "✅ 791 is on the allowlist.", sent[123])
+	send(791, "hello")
+	assert.Equal(t, 2, llmCalls)
+	send(123, "/disallow 791")
+	send(791, "hello")
+	assert.Equal(t, 2, llmCalls)
+
+	// The denylist applies in every mode and is silent.
+	b.config.AccessMode = AccessModeOpen
+	send(123, "/deny 789")
+	assert.Contains(t, sent[123], "is on the denylist")
+	delete(sent, 789)
+	send(789, "hello")
+	assert.Equal(t, 2, llmCalls)
+	assert.NotContains(t, sent, int64(789))
+	send(123, "/deny 123")
+	assert.Equal(t, "You can't deny yourself, the owner or a co-owner.", sent[123])
+
+	send(123, "/access")
+	assert.Contains(t, sent[123], "Access mode: open")
+	assert.Contains(t, sent[123], "Allowlist: 790")
+	assert.Contains(t, sent[123], "Denylist: 789")
+
+	send(123, "/undeny 789")
+	send(789, "hello")
+	assert.Equal(t, 3, llmCalls)
+}
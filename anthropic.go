@@ -5,84 +5,72 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/liushuangls/go-anthropic/v2"
 )
 
-// ErrModelNotFound is returned when the configured Anthropic model is no longer available
+// ErrModelNotFound is returned when the configured model is no longer available
 // (deprecated or removed). Callers can use errors.Is to detect this and surface an
 // actionable message to admins/owners while keeping the response vague for regular users.
 var ErrModelNotFound = errors.New("model not found or deprecated")
 
-func (b *Bot) getAnthropicResponse(ctx context.Context, messages []anthropic.Message, isNewChat, isOwner, isEmojiOnly bool, username string, firstName string, lastName string, isPremium bool, languageCode string, messageTime int) (string, error) {
-	// Use prompts from config
-	var systemMessage string
-	if isNewChat {
-		systemMessage = b.config.SystemPrompts["new_chat"]
-	} else {
-		systemMessage = b.config.SystemPrompts["continue_conversation"]
-	}
+func (b *Bot) getAnthropicResponse(ctx context.Context, chatID, userID int64, messages []anthropic.Message, isNewChat, isOwner, isEmojiOnly bool, username string, firstName string, lastName string, isPremium bool, languageCode string, messageTime int) (string, error) {
+	request := b.buildCompletionRequest(ctx, chatID, userID, messages, isNewChat, isOwner, isEmojiOnly, username, firstName, lastName, isPremium, languageCode, messageTime)
 
-	// Combine default prompt with custom instructions
-	systemMessage = b.config.SystemPrompts["default"] + " " + b.config.SystemPrompts["custom_instructions"] + " " + systemMessage
-
-	// Handle username placeholder
-	usernameValue := username
-	if username == "" {
-		usernameValue = "unknown" // Use "unknown" when username is not available
+	resp, err := b.completeWithTools(ctx, request, userID)
+	if err != nil {
+		return "", err
 	}
-	systemMessage = strings.ReplaceAll(systemMessage, "{username}", usernameValue)
+	b.recordUsage(ctx, request.Model, resp.Usage)
 
-	// Handle firstname placeholder
-	firstnameValue := firstName
-	if firstName == "" {
-		firstnameValue = "unknown" // Use "unknown" when first name is not available
-	}
-	systemMessage = strings.ReplaceAll(systemMessage, "{firstname}", firstnameValue)
+	return resp.Text, nil
+}
 
-	// Handle lastname placeholder
-	lastnameValue := lastName
-	if lastName == "" {
-		lastnameValue = "" // Empty string when last name is not available
+// buildCompletionRequest renders the system prompt for the current user, followed by their own
+// instructions, and wraps it, together with the conversation, into a provider-agnostic request.
+func (b *Bot) buildCompletionRequest(ctx context.Context, chatID, userID int64, messages []anthropic.Message, isNewChat, isOwner, isEmojiOnly bool, username string, firstName string, lastName string, isPremium bool, languageCode string, messageTime int) CompletionRequest {
+	// Use prompts from config, overridden by the chat's persona
+	prompts := b.config.SystemPrompts
+	model, temperature := string(b.config.Model), b.config.Temperature
+	personaName, persona, hasPersona := b.chatPersona(chatID)
+	if hasPersona {
+		prompts = persona.systemPrompts(prompts)
+		if persona.Model != "" {
+			model = persona.Model
+		}
+		if persona.Temperature != nil {
+			temperature = persona.Temperature
+		}
 	}
-	systemMessage = strings.ReplaceAll(systemMessage, "{lastname}", lastnameValue)
+	data := b.promptVariables(ctx, chatID, userID, isNewChat, isOwner, personaName, username, firstName, lastName, isPremium, languageCode, messageTime)
+	prompt := func(key string) string { return renderPrompt(key, prompts[key], data) }
 
-	// Handle language code placeholder
-	langValue := languageCode
-	if languageCode == "" {
-		langValue = "en" // Default to English when language code is not available
+	var systemMessage string
+	if isNewChat {
+		systemMessage = prompt("new_chat")
+	} else {
+		systemMessage = prompt("continue_conversation")
 	}
-	systemMessage = strings.ReplaceAll(systemMessage, "{language}", langValue)
 
-	// Handle premium status
-	premiumStatus := "regular user"
-	if isPremium {
-		premiumStatus = "premium user"
+	// Combine default prompt with custom instructions
+	systemMessage = prompt("default") + " " + prompt("custom_instructions") + " " + systemMessage
+
+	if !isOwner {
+		systemMessage += " " + prompt("avoid_sensitive")
 	}
-	systemMessage = strings.ReplaceAll(systemMessage, "{premium_status}", premiumStatus)
 
-	// Handle time awareness
-	timeObj := time.Unix(int64(messageTime), 0)
-	hour := timeObj.Hour()
-	var timeContext string
-	if hour >= 5 && hour < 12 {
-		timeContext = "morning"
-	} else if hour >= 12 && hour < 18 {
-		timeContext = "afternoon"
-	} else if hour >= 18 && hour < 22 {
-		timeContext = "evening"
-	} else {
-		timeContext = "night"
+	if isEmojiOnly {
+		systemMessage += " " + prompt("respond_with_emojis")
 	}
-	systemMessage = strings.ReplaceAll(systemMessage, "{time_context}", timeContext)
 
-	if !isOwner {
-		systemMessage += " " + b.config.SystemPrompts["avoid_sensitive"]
+	// The user's own instructions come after the bot's and are not rendered as a template
+	if instructions := b.userInstructions(userID); instructions != "" {
+		systemMessage += "\n\nThe user's own instructions (follow them unless they conflict with the above):\n" + instructions
 	}
 
-	if isEmojiOnly {
-		systemMessage += " " + b.config.SystemPrompts["respond_with_emojis"]
+	// Carry older turns that no longer fit in memory via the rolling summary
+	if summary := b.chatSummaryText(chatID); summary != "" {
+		systemMessage += "\n\nSummary of the earlier conversation:\n" + summary
 	}
 
 	// Debug logging
@@ -108,33 +96,122 @@ func (b *Bot) getAnthropicResponse(ctx context.Context, messages []anthropic.Mes
 		}
 	}
 
-	model := anthropic.Model(b.config.Model)
-
 	// Create the request
+	request := CompletionRequest{
+		Model:       model,
+		Messages:    messages,
+		System:      systemMessage,
+		MaxTokens:   b.maxOutputTokens(),
+		Temperature: temperature, // nil leaves the provider's default
+	}
+
+	return request
+}
+
+// anthropicProvider implements LLMProvider on top of the Anthropic Messages API.
+type anthropicProvider struct {
+	client *anthropic.Client
+}
+
+func newAnthropicProvider(apiKey string) *anthropicProvider {
+	return &anthropicProvider{client: anthropic.NewClient(apiKey)}
+}
+
+// Complete sends the conversation to Anthropic and returns the first text block.
+func (p *anthropicProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
 	request := anthropic.MessagesRequest{
-		Model:     model, // Now `model` is of type anthropic.Model
-		Messages:  messages,
-		System:    systemMessage,
-		MaxTokens: 1000,
+		Model:       anthropic.Model(req.Model),
+		Messages:    req.Messages,
+		System:      req.System,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Tools:       req.Tools,
 	}
 
-	// Apply temperature if set in config
-	if b.config.Temperature != nil {
-		request.Temperature = b.config.Temperature
+	resp, err := p.client.CreateMessages(ctx, request)
+	if err != nil {
+		var apiErr *anthropic.APIError
+		if errors.As(err, &apiErr) && apiErr.IsNotFoundErr() {
+			return CompletionResponse{}, fmt.Errorf("%w: %s", ErrModelNotFound, req.Model)
+		}
+		return CompletionResponse{}, fmt.Errorf("error creating Anthropic message: %w", err)
 	}
 
-	resp, err := b.anthropicClient.CreateMessages(ctx, request)
+	return anthropicCompletionResponse(resp)
+}
+
+// CountTokens returns the exact number of input tokens of the request via the count-tokens API.
+func (p *anthropicProvider) CountTokens(ctx context.Context, req CompletionRequest) (int, error) {
+	resp, err := p.client.CountTokens(ctx, anthropic.MessagesRequest{
+		Model:    anthropic.Model(req.Model),
+		Messages: req.Messages,
+		System:   req.System,
+		Tools:    req.Tools,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error counting Anthropic tokens: %w", err)
+	}
+	return resp.InputTokens, nil
+}
+
+// CompleteStream is like Complete but invokes onDelta with each text fragment as it arrives.
+func (p *anthropicProvider) CompleteStream(ctx context.Context, req CompletionRequest, onDelta func(string)) (CompletionResponse, error) {
+	request := anthropic.MessagesStreamRequest{
+		MessagesRequest: anthropic.MessagesRequest{
+			Model:       anthropic.Model(req.Model),
+			Messages:    req.Messages,
+			System:      req.System,
+			MaxTokens:   req.MaxTokens,
+			Temperature: req.Temperature,
+		},
+		OnContentBlockDelta: func(data anthropic.MessagesEventContentBlockDeltaData) {
+			if data.Delta.Type == anthropic.MessagesContentTypeTextDelta && data.Delta.Text != nil {
+				onDelta(*data.Delta.Text)
+			}
+		},
+	}
+
+	resp, err := p.client.CreateMessagesStream(ctx, request)
 	if err != nil {
 		var apiErr *anthropic.APIError
 		if errors.As(err, &apiErr) && apiErr.IsNotFoundErr() {
-			return "", fmt.Errorf("%w: %s", ErrModelNotFound, b.config.Model)
+			return CompletionResponse{}, fmt.Errorf("%w: %s", ErrModelNotFound, req.Model)
+		}
+		return CompletionResponse{}, fmt.Errorf("error streaming Anthropic message: %w", err)
+	}
+
+	return anthropicCompletionResponse(resp)
+}
+
+// anthropicCompletionResponse collects the text blocks, tool_use blocks and usage of an Anthropic response.
+func anthropicCompletionResponse(resp anthropic.MessagesResponse) (CompletionResponse, error) {
+	var result CompletionResponse
+	var texts []string
+	for _, content := range resp.Content {
+		switch content.Type {
+		case anthropic.MessagesContentTypeText:
+			texts = append(texts, content.GetText())
+		case anthropic.MessagesContentTypeToolUse:
+			if content.MessageContentToolUse != nil {
+				result.ToolCalls = append(result.ToolCalls, ToolCall{
+					ID:    content.ID,
+					Name:  content.Name,
+					Input: content.Input,
+				})
+			}
 		}
-		return "", fmt.Errorf("error creating Anthropic message: %w", err)
 	}
 
-	if len(resp.Content) == 0 || resp.Content[0].Type != anthropic.MessagesContentTypeText {
-		return "", fmt.Errorf("unexpected response format from Anthropic")
+	if len(texts) == 0 && len(result.ToolCalls) == 0 {
+		return CompletionResponse{}, fmt.Errorf("unexpected response format from Anthropic")
 	}
 
-	return resp.Content[0].GetText(), nil
+	result.Text = strings.Join(texts, "\n")
+	result.Usage = Usage{
+		InputTokens:              resp.Usage.InputTokens,
+		OutputTokens:             resp.Usage.OutputTokens,
+		CacheCreationInputTokens: resp.Usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     resp.Usage.CacheReadInputTokens,
+	}
+	return result, nil
 }
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf16"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -15,17 +17,28 @@ import (
 )
 
 type Bot struct {
-	tgBot           TelegramClient
-	db              *gorm.DB
-	anthropicClient *anthropic.Client
-	chatMemories    map[int64]*ChatMemory
-	memorySize      int
-	chatMemoriesMu  sync.RWMutex
-	config          BotConfig
-	userLimiters    map[int64]*userLimiter
-	userLimitersMu  sync.RWMutex
-	clock           Clock
-	botID           uint // Reference to BotModel.ID
+	tgBot          TelegramClient
+	db             *gorm.DB
+	llm            LLMProvider
+	chatMemories   map[int64]*ChatMemory
+	memorySize     int
+	chatMemoriesMu sync.RWMutex
+	config         BotConfig
+	userLimiters   map[int64]*userLimiter
+	userLimitersMu sync.RWMutex
+	lastLimiterGC  time.Time // When stale user limiters were last swept
+	clock          Clock
+	botID          uint             // Reference to BotModel.ID
+	tools          *ToolRegistry    // Tools the model may call when enable_tools is set
+	commands       *CommandRegistry // Commands users can send, which also make up the palettes
+	images         *imageCache      // Downloaded images of messages in chat memory
+	stickers       *imageCache      // Sticker images keyed by StickerFileID
+	me             *models.User     // The bot's own user, fetched lazily by botUser
+	meMu           sync.Mutex
+	quotaAlerts    map[string]bool // Bot budget alerts already sent to the owner, keyed by period and level
+	quotaAlertsMu  sync.Mutex
+	transfer       *ownerTransfer // Pending /transfer_owner request, if any
+	transferMu     sync.Mutex
 }
 
 // Helper function to determine message type
@@ -33,6 +46,12 @@ func messageType(msg *models.Message) string {
 	if msg.Sticker != nil {
 		return "sticker"
 	}
+	if len(msg.Photo) > 0 {
+		return "photo"
+	}
+	if msg.Document != nil {
+		return "document"
+	}
 	return "text"
 }
 
@@ -55,8 +74,7 @@ func NewBot(db *gorm.DB, config BotConfig, clock Clock, tgClient TelegramClient)
 	err = db.Where("telegram_id = ? AND bot_id = ?", config.OwnerTelegramID, botEntry.ID).First(&owner).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		// Assign the "owner" role
-		var ownerRole Role
-		err := db.Where("name = ?", "owner").First(&ownerRole).Error
+		ownerRole, err := roleForBot(db, botEntry.ID, "owner")
 		if err != nil {
 			return nil, fmt.Errorf("owner role not found: %w", err)
 		}
@@ -78,21 +96,55 @@ func NewBot(db *gorm.DB, config BotConfig, clock Clock, tgClient TelegramClient)
 		}
 	} else if err != nil {
 		return nil, err
+	} else if !owner.IsOwner {
+		// Ownership was handed over with /transfer_owner; the database is authoritative.
+		InfoLogger.Printf("[%s] User %d is no longer the owner; update owner_telegram_id to the current owner", config.ID, config.OwnerTelegramID)
 	}
 
-	// Use the per-bot Anthropic API key
-	anthropicClient := anthropic.NewClient(config.AnthropicAPIKey)
+	// Build the per-bot LLM provider (Anthropic unless the config selects another backend)
+	llm, err := newLLMProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Register the built-in tools; each is still gated per user by its scope.
+	tools := newToolRegistry()
+	for _, tool := range builtinTools() {
+		if err := tools.Register(tool); err != nil {
+			return nil, fmt.Errorf("failed to register tool: %w", err)
+		}
+	}
+
+	if err := createPersonaScopes(db, config.Personas); err != nil {
+		return nil, fmt.Errorf("failed to create persona scopes: %w", err)
+	}
+
+	commands := newCommandRegistry()
+	for _, cmd := range builtinCommands() {
+		if err := commands.Register(cmd); err != nil {
+			return nil, fmt.Errorf("failed to register command: %w", err)
+		}
+	}
 
 	b := &Bot{
-		db:              db,
-		anthropicClient: anthropicClient,
-		chatMemories:    make(map[int64]*ChatMemory),
-		memorySize:      config.MemorySize,
-		config:          config,
-		userLimiters:    make(map[int64]*userLimiter),
-		clock:           clock,
-		botID:           botEntry.ID, // Ensure BotModel has ID field
-		tgBot:           tgClient,
+		db:           db,
+		llm:          llm,
+		chatMemories: make(map[int64]*ChatMemory),
+		memorySize:   config.MemorySize,
+		config:       config,
+		userLimiters: make(map[int64]*userLimiter),
+		clock:        clock,
+		botID:        botEntry.ID, // Ensure BotModel has ID field
+		tgBot:        tgClient,
+		tools:        tools,
+		commands:     commands,
+		images:       newImageCache(max(config.MemorySize*2, 1)),
+		stickers:     newImageCache(stickerCacheSize),
+		quotaAlerts:  make(map[string]bool),
+	}
+
+	if err := b.loadUserLimiters(); err != nil {
+		return nil, fmt.Errorf("failed to load rate limit state: %w", err)
 	}
 
 	if tgClient == nil {
@@ -107,8 +159,13 @@ func NewBot(db *gorm.DB, config BotConfig, clock Clock, tgClient TelegramClient)
 	return b, nil
 }
 
-// Start begins the bot's operation.
+// Start begins the bot's operation and blocks until ctx is cancelled.
+// In webhook mode the update handler must already be registered via registerWebhook.
 func (b *Bot) Start(ctx context.Context) {
+	if b.config.Transport == TransportWebhook {
+		b.runWebhook(ctx)
+		return
+	}
 	b.tgBot.Start(ctx)
 }
 
@@ -136,7 +193,7 @@ func (b *Bot) getOrCreateUser(userID int64, username string, isOwner bool) (User
 				roleName = "user" // Assign "user" role to non-owner users
 			}
 
-			err := b.db.Where("name = ?", roleName).First(&role).Error
+			role, err := b.getRoleByName(roleName)
 			if err != nil {
 				return User{}, fmt.Errorf("failed to get role: %w", err)
 			}
@@ -170,8 +227,17 @@ func (b *Bot) getOrCreateUser(userID int64, username string, isOwner bool) (User
 }
 
 func (b *Bot) getRoleByName(roleName string) (Role, error) {
+	return roleForBot(b.db, b.botID, roleName)
+}
+
+// roleForBot returns the role a bot knows under roleName, with its scopes: the bot's own role if
+// it has one, the global role otherwise.
+func roleForBot(db *gorm.DB, botID uint, roleName string) (Role, error) {
 	var role Role
-	err := b.db.Where("name = ?", roleName).First(&role).Error
+	err := db.Preload("Scopes").
+		Where("name = ? AND bot_id IN ?", roleName, []uint{0, botID}).
+		Order("bot_id DESC").
+		First(&role).Error
 	return role, err
 }
 
@@ -222,7 +288,7 @@ func (b *Bot) getOrCreateChatMemory(chatID int64) *ChatMemory {
 				// Fetch existing messages only if it's not a new chat
 				err := b.db.Where("chat_id = ? AND bot_id = ?", chatID, b.botID).
 					Order("timestamp desc").
-					Limit(b.memorySize * 2).
+					Limit(b.memoryCapacity()).
 					Find(&messages).Error
 
 				if err != nil {
@@ -240,7 +306,7 @@ func (b *Bot) getOrCreateChatMemory(chatID int64) *ChatMemory {
 
 			chatMemory = &ChatMemory{
 				Messages: messages,
-				Size:     b.memorySize * 2,
+				Size:     b.memoryCapacity(),
 			}
 
 			b.chatMemories[chatID] = chatMemory
@@ -264,6 +330,15 @@ func (b *Bot) addMessageToChatMemory(chatMemory *ChatMemory, message Message) {
 	}
 }
 
+// buildContext brings the chat's rolling summary and images up to date and returns the
+// conversation to send to the model.
+func (b *Bot) buildContext(ctx context.Context, chatID int64) []anthropic.Message {
+	chatMemory := b.getOrCreateChatMemory(chatID)
+	b.updateChatSummary(ctx, chatID, chatMemory)
+	b.loadContextImages(ctx, chatMemory)
+	return b.prepareContextMessages(chatMemory)
+}
+
 func (b *Bot) prepareContextMessages(chatMemory *ChatMemory) []anthropic.Message {
 	b.chatMemoriesMu.RLock()
 	defer b.chatMemoriesMu.RUnlock()
@@ -279,8 +354,14 @@ func (b *Bot) prepareContextMessages(chatMemory *ChatMemory) []anthropic.Message
 	// returning an error. This can happen after a /clear (which only deletes user
 	// messages, leaving assistant messages in the DB) followed by a restart.
 	// See: https://platform.claude.com/docs/en/api/messages
+	// With a token budget only the newest messages that fit are sent.
+	window := b.contextWindow(chatMemory)
+	if len(window) < len(chatMemory.Messages) {
+		InfoLogger.Printf("Token budget keeps %d of %d messages", len(window), len(chatMemory.Messages))
+	}
+
 	var contextMessages []anthropic.Message
-	for _, msg := range chatMemory.Messages {
+	for _, msg := range window {
 		role := anthropic.RoleUser
 		if !msg.IsUser {
 			role = anthropic.RoleAssistant
@@ -291,12 +372,20 @@ func (b *Bot) prepareContextMessages(chatMemory *ChatMemory) []anthropic.Message
 			// Skip empty messages
 			continue
 		}
+		if msg.IsUser && isGroupChatID(msg.ChatID) {
+			textContent = speakerLabel(msg) + ": " + textContent
+		}
+
+		// Images go before their caption, as recommended for vision prompts.
+		var content []anthropic.MessageContent
+		if image, ok := b.imageContent(msg); ok {
+			content = append(content, image)
+		}
+		content = append(content, anthropic.NewTextMessageContent(textContent))
 
 		contextMessages = append(contextMessages, anthropic.Message{
-			Role: role,
-			Content: []anthropic.MessageContent{
-				anthropic.NewTextMessageContent(textContent),
-			},
+			Role:    role,
+			Content: content,
 		})
 	}
 	return contextMessages
@@ -319,7 +408,7 @@ func roleHasScope(role Role, scope string) bool {
 }
 
 // hasScope reports whether the user identified by userID holds the given scope for this bot.
-// Owners implicitly hold all scopes regardless of their assigned role.
+// Owners and co-owners implicitly hold all scopes regardless of their assigned role.
 func (b *Bot) hasScope(userID int64, scope string) bool {
 	var user User
 	if err := b.db.Preload("Role.Scopes").
@@ -327,34 +416,18 @@ func (b *Bot) hasScope(userID int64, scope string) bool {
 		First(&user).Error; err != nil {
 		return false
 	}
-	if user.IsOwner {
+	if b.actsAsOwner(user) {
 		return true
 	}
 	return roleHasScope(user.Role, scope)
 }
 
-// publicBotCommands are shown to every user in the Telegram command palette.
-var publicBotCommands = []models.BotCommand{
-	{Command: "stats", Description: "Get bot statistics. Usage: /stats or /stats user [user_id]"},
-	{Command: "whoami", Description: "Get your user information"},
-	{Command: "clear", Description: "Clear chat history (soft delete). Admins: /clear [user_id]"},
-}
-
-// adminBotCommands are shown only in admin/owner chats via BotCommandScopeChatMember.
-var adminBotCommands = []models.BotCommand{
-	{Command: "clear_hard", Description: "Clear chat history (permanently delete). Admins: /clear_hard [user_id]"},
-	{Command: "set_model", Description: "Switch the AI model (admin/owner only). Usage: /set_model <model-id>"},
-}
-
-// registerAdminCommandsForUser scopes the full command palette to a specific user's private chat.
+// registerAdminCommandsForUser scopes a command palette to a specific user's private chat.
 // In Telegram private chats, chat_id == user_id, so both fields carry the same value.
 // Errors are logged but treated as non-fatal: the user retains access via permission checks.
-func (b *Bot) registerAdminCommandsForUser(ctx context.Context, telegramID int64) {
-	allCommands := make([]models.BotCommand, 0, len(publicBotCommands)+len(adminBotCommands))
-	allCommands = append(allCommands, publicBotCommands...)
-	allCommands = append(allCommands, adminBotCommands...)
+func (b *Bot) registerAdminCommandsForUser(ctx context.Context, telegramID int64, commands []models.BotCommand) {
 	_, err := b.tgBot.SetMyCommands(ctx, &bot.SetMyCommandsParams{
-		Commands: allCommands,
+		Commands: commands,
 		Scope:    &models.BotCommandScopeChat{ChatID: telegramID},
 	})
 	if err != nil {
@@ -362,22 +435,20 @@ func (b *Bot) registerAdminCommandsForUser(ctx context.Context, telegramID int64
 	}
 }
 
-// setElevatedCommands registers the full command palette (public + admin) for every user
-// whose role carries the model:set scope, or who is the bot owner. Called once at startup
-// and uses the freshly created tgBot directly (b.tgBot is not yet assigned at that point).
-func setElevatedCommands(tgBot TelegramClient, users []User) {
-	allCommands := make([]models.BotCommand, 0, len(publicBotCommands)+len(adminBotCommands))
-	allCommands = append(allCommands, publicBotCommands...)
-	allCommands = append(allCommands, adminBotCommands...)
+// setElevatedCommands registers a personal command palette (public + the admin commands they
+// can run) for every user who can run at least one admin command. Called once at startup and
+// uses the freshly created tgBot directly (b.tgBot is not yet assigned at that point).
+func (b *Bot) setElevatedCommands(tgBot TelegramClient, users []User) {
 	for _, u := range users {
 		if u.TelegramID == 0 {
 			continue // skip placeholder users not yet seen in a chat
 		}
-		if !u.IsOwner && !roleHasScope(u.Role, ScopeModelSet) {
+		commands, admin := b.userPalette(context.Background(), u)
+		if !admin {
 			continue
 		}
 		_, err := tgBot.SetMyCommands(context.Background(), &bot.SetMyCommandsParams{
-			Commands: allCommands,
+			Commands: commands,
 			Scope:    &models.BotCommandScopeChat{ChatID: u.TelegramID},
 		})
 		if err != nil {
@@ -398,7 +469,7 @@ func initTelegramBot(token string, b *Bot) (TelegramClient, error) {
 
 	// Register public commands for all users.
 	_, err = tgBot.SetMyCommands(context.Background(), &bot.SetMyCommandsParams{
-		Commands: publicBotCommands,
+		Commands: b.paletteCommands(palettePublic),
 		Scope:    &models.BotCommandScopeDefault{},
 	})
 	if err != nil {
@@ -406,32 +477,93 @@ func initTelegramBot(token string, b *Bot) (TelegramClient, error) {
 		return nil, err
 	}
 
-	// Register full command palette (public + admin) scoped to each known elevated user.
-	// BotCommandScopeChatMember targets the user's private DM with the bot (chat_id == user_id).
+	// Register group settings for group administrators (public commands plus /group).
+	if _, err := tgBot.SetMyCommands(context.Background(), &bot.SetMyCommandsParams{
+		Commands: b.paletteCommands(palettePublic, paletteGroupAdmin),
+		Scope:    &models.BotCommandScopeAllChatAdministrators{},
+	}); err != nil {
+		ErrorLogger.Printf("Warning: could not set group admin commands: %v", err)
+	}
+
+	// Register each known elevated user's palette: the public commands plus the admin commands
+	// their scopes allow. BotCommandScopeChat targets the user's private DM with the bot (chat_id == user_id).
 	// Elevation is determined by scope rather than role name, so renaming roles requires no code change.
 	// This is best-effort: failures are logged but do not prevent the bot from starting.
 	var allUsers []User
 	if err := b.db.Preload("Role.Scopes").Where("bot_id = ?", b.botID).Find(&allUsers).Error; err != nil {
 		ErrorLogger.Printf("Warning: could not query users for command scoping: %v", err)
 	} else {
-		setElevatedCommands(tgBot, allUsers)
+		b.setElevatedCommands(tgBot, allUsers)
 	}
 
 	return tgBot, nil
 }
 
+// telegramMessageLimit is the most characters (UTF-16 code units) Telegram accepts in one message.
+const telegramMessageLimit = 4096
+
+// messageLength returns the length of text as Telegram counts it.
+func messageLength(text string) int {
+	n := 0
+	for _, r := range text {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// splitMessage splits text into parts of at most limit characters, breaking after a newline or
+// space where one falls in the second half of a part.
+func splitMessage(text string, limit int) []string {
+	var parts []string
+	for messageLength(text) > limit {
+		cut, lastBreak, size := 0, 0, 0
+		for i, r := range text {
+			if size += utf16.RuneLen(r); size > limit {
+				cut = i
+				break
+			}
+			if r == '\n' || r == ' ' {
+				lastBreak = i + 1
+			}
+		}
+		if lastBreak > cut/2 {
+			cut = lastBreak
+		}
+		parts = append(parts, text[:cut])
+		text = text[cut:]
+	}
+	return append(parts, text)
+}
+
+// sendResponse stores text as the bot's reply and sends it, split into several messages when it
+// exceeds Telegram's length limit.
 func (b *Bot) sendResponse(ctx context.Context, chatID int64, text string, businessConnectionID string) error {
 	// Pass the outgoing message through the centralized screen for storage and chat memory update
-	_, err := b.screenOutgoingMessage(chatID, text)
+	_, err := b.screenOutgoingMessage(ctx, chatID, text)
 	if err != nil {
 		ErrorLogger.Printf("Error storing assistant message: %v", err)
 		return err
 	}
 
+	parts := splitMessage(text, telegramMessageLimit)
+	if err := b.sendText(ctx, chatID, parts[0], businessConnectionID, replyParameters(ctx)); err != nil {
+		return err
+	}
+	for _, part := range parts[1:] {
+		if err := b.sendText(ctx, chatID, part, businessConnectionID, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendText sends one message of at most telegramMessageLimit characters without storing it.
+func (b *Bot) sendText(ctx context.Context, chatID int64, text string, businessConnectionID string, reply *models.ReplyParameters) error {
 	// Prepare message parameters
 	params := &bot.SendMessageParams{
-		ChatID: chatID,
-		Text:   text,
+		ChatID:          chatID,
+		Text:            text,
+		ReplyParameters: reply,
 	}
 
 	if businessConnectionID != "" {
@@ -439,7 +571,7 @@ func (b *Bot) sendResponse(ctx context.Context, chatID int64, text string, busin
 	}
 
 	// Send the message via Telegram client
-	_, err = b.tgBot.SendMessage(ctx, params)
+	_, err := b.tgBot.SendMessage(ctx, params)
 	if err != nil {
 		ErrorLogger.Printf("[%s] Error sending message to chat %d with BusinessConnectionID %s: %v",
 			b.config.ID, chatID, businessConnectionID, err)
@@ -498,6 +630,13 @@ func (b *Bot) sendStats(ctx context.Context, chatID int64, userID int64, targetU
 					statsMessage += fmt.Sprintf("\n%d. @%s — %d messages", i+1, name, entry.MsgCount)
 				}
 			}
+
+			// Token usage and spend are bot-wide totals, so only shown to those who may see every user.
+			if report, err := b.usageReport(0); err != nil {
+				ErrorLogger.Printf("Error fetching usage totals: %v", err)
+			} else {
+				statsMessage += "\n\n" + report
+			}
 		}
 
 		// Send the response through the centralized screen
@@ -546,6 +685,12 @@ func (b *Bot) sendStats(ctx context.Context, chatID int64, userID int64, targetU
 		totalMessages,
 	)
 
+	if report, err := b.usageReport(targetUserID); err != nil {
+		ErrorLogger.Printf("Error fetching usage for user %d: %v", targetUserID, err)
+	} else {
+		statsMessage += "\n\n" + report
+	}
+
 	if err := b.sendResponse(ctx, chatID, statsMessage, businessConnectionID); err != nil {
 		ErrorLogger.Printf("Error sending user stats message: %v", err)
 	}
@@ -678,8 +823,16 @@ func (b *Bot) screenIncomingMessage(message *models.Message) (Message, error) {
 	if message.Voice != nil {
 		messageText = "[Voice message]"
 	}
+	image, hasImage := b.imageFromMessage(message)
+	if hasImage {
+		messageText = strings.TrimSpace(message.Caption)
+		if messageText == "" {
+			messageText = imagePlaceholder
+		}
+	}
 
 	userMessage := b.createMessage(message.Chat.ID, message.From.ID, message.From.Username, userRole, messageText, true)
+	userMessage.DisplayName = strings.TrimSpace(message.From.FirstName + " " + message.From.LastName)
 
 	// Handle sticker-specific details if present
 	if message.Sticker != nil {
@@ -690,6 +843,13 @@ func (b *Bot) screenIncomingMessage(message *models.Message) (Message, error) {
 		}
 	}
 
+	// Keep the image reference so it can be re-sent as context while within memory_size. Images of
+	// users without vision:use are never kept, whether or not the bot answers them.
+	if hasImage && b.hasScope(message.From.ID, ScopeVisionUse) {
+		userMessage.ImageFileID = image.FileID
+		userMessage.ImageMediaType = image.MediaType
+	}
+
 	// Get the chat memory before storing the message
 	chatMemory := b.getOrCreateChatMemory(message.Chat.ID)
 
@@ -705,8 +865,9 @@ func (b *Bot) screenIncomingMessage(message *models.Message) (Message, error) {
 }
 
 // screenOutgoingMessage handles storing of outgoing messages and updating chat memory.
-// It also marks the most recent unanswered user message as answered.
-func (b *Bot) screenOutgoingMessage(chatID int64, response string) (Message, error) {
+// It also marks the most recent unanswered user message as answered and stores the model usage
+// recorded in ctx on the message.
+func (b *Bot) screenOutgoingMessage(ctx context.Context, chatID int64, response string) (Message, error) {
 	if b.config.DebugScreening {
 		start := time.Now()
 		defer func() {
@@ -722,6 +883,7 @@ func (b *Bot) screenOutgoingMessage(chatID int64, response string) (Message, err
 
 	// Create and store the assistant message
 	assistantMessage := b.createMessage(chatID, 0, "", string(anthropic.RoleAssistant), response, false)
+	b.applyUsage(ctx, &assistantMessage)
 	if err := b.storeMessage(&assistantMessage); err != nil {
 		return Message{}, err
 	}
@@ -754,25 +916,12 @@ func (b *Bot) promoteUserToAdmin(promoterID, userToPromoteID int64) error {
 	}
 
 	// Get the user to promote
-	userToPromote, err := b.getOrCreateUser(userToPromoteID, "", false)
+	userToPromote, err := b.resolveUser(strconv.FormatInt(userToPromoteID, 10))
 	if err != nil {
 		return err
 	}
 
-	// Get the admin role
-	var adminRole Role
-	if err := b.db.Where("name = ?", "admin").First(&adminRole).Error; err != nil {
-		return err
-	}
-
-	// Update the user's role
-	userToPromote.RoleID = adminRole.ID
-	userToPromote.Role = adminRole
-	if err := b.db.Save(&userToPromote).Error; err != nil {
-		return err
-	}
-
-	// Surface admin commands in the newly promoted user's private chat.
-	b.registerAdminCommandsForUser(context.Background(), userToPromoteID)
-	return nil
+	// Update the user's role; this also surfaces admin commands in their private chat.
+	_, err = b.changeUserRole(context.Background(), promoterID, userToPromote, "admin")
+	return err
 }
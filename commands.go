@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot/models"
+)
+
+// argKind is the type of a command argument.
+type argKind int
+
+const (
+	argWord    argKind = iota // A single word, passed on as is
+	argInt                    // A whole number, such as a user or chat ID
+	argLiteral                // A fixed keyword equal to the argument's name, e.g. "user" in /stats user
+	argRest                   // All remaining words; must be the last argument
+)
+
+// commandArg describes one positional argument of a command.
+type commandArg struct {
+	name     string
+	kind     argKind
+	optional bool
+}
+
+// paletteKind says in which Telegram command palette a command is listed.
+type paletteKind int
+
+const (
+	paletteHidden     paletteKind = iota // Not listed, but still handled
+	palettePublic                        // Every chat
+	paletteAdmin                         // Private chats of elevated users
+	paletteGroupAdmin                    // Group administrators
+)
+
+// Command is a bot command. The router checks Scope, parses Args and replies with the generated
+// usage on errors before Handler runs; the palettes are generated from Description and Args.
+type Command struct {
+	Name        string // Without the leading slash
+	Description string
+	Scope       string // Required scope; empty when everyone may run it or the handler checks permissions itself
+	Args        []commandArg
+	Usage       string // Overrides the usage generated from Args, without the "Usage: " prefix
+	Example     string // Appended to usage errors, e.g. "/ban 12345 7d"
+	Palette     paletteKind
+	Handler     func(b *Bot, ctx context.Context, c *commandCall)
+	Visible     func(b *Bot, ctx context.Context, c *commandCall) bool // Hides the command from /help beyond Scope; nil shows it
+}
+
+// commandCall is one invocation of a command with its arguments parsed.
+type commandCall struct {
+	message              *models.Message
+	businessConnectionID string
+	words                map[string]string
+	ints                 map[string]int64
+	rest                 []string     // Words of a trailing argRest argument
+	args                 string       // Everything after the command as sent, line breaks included
+	reply                func(string) // Answers in the chat the command came from
+}
+
+func (c *commandCall) chatID() int64 { return c.message.Chat.ID }
+func (c *commandCall) userID() int64 { return c.message.From.ID }
+
+// has reports whether an optional argument was given.
+func (c *commandCall) has(name string) bool {
+	_, ok := c.words[name]
+	return ok
+}
+
+// str returns an argument as given, or "" when omitted.
+func (c *commandCall) str(name string) string { return c.words[name] }
+
+// int returns an argInt argument, or 0 when omitted.
+func (c *commandCall) int(name string) int64 { return c.ints[name] }
+
+// commandNamePattern matches the command names Telegram accepts in palettes.
+var commandNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// CommandRegistry holds the commands of a bot, in registration order.
+type CommandRegistry struct {
+	commands map[string]*Command
+	order    []string
+}
+
+func newCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{commands: make(map[string]*Command)}
+}
+
+// Register adds a command. Names must be unique and valid palette entries, and only the last
+// argument may be argRest.
+func (r *CommandRegistry) Register(cmd Command) error {
+	if !commandNamePattern.MatchString(cmd.Name) {
+		return fmt.Errorf("invalid command name %q", cmd.Name)
+	}
+	if cmd.Handler == nil {
+		return fmt.Errorf("command /%s has no handler", cmd.Name)
+	}
+	if _, exists := r.commands[cmd.Name]; exists {
+		return fmt.Errorf("command /%s is already registered", cmd.Name)
+	}
+	for i, arg := range cmd.Args {
+		if arg.kind == argRest && i != len(cmd.Args)-1 {
+			return fmt.Errorf("command /%s: only the last argument may take the remaining words", cmd.Name)
+		}
+	}
+	r.commands[cmd.Name] = &cmd
+	r.order = append(r.order, cmd.Name)
+	return nil
+}
+
+// Get returns the command with the given name (without slash).
+func (r *CommandRegistry) Get(name string) (*Command, bool) {
+	cmd, ok := r.commands[name]
+	return cmd, ok
+}
+
+// All returns every registered command in registration order.
+func (r *CommandRegistry) All() []*Command {
+	all := make([]*Command, 0, len(r.order))
+	for _, name := range r.order {
+		all = append(all, r.commands[name])
+	}
+	return all
+}
+
+// usage returns how to call the command, e.g. "/ban <user_id> <duration|permanent>".
+func (cmd *Command) usage() string {
+	if cmd.Usage != "" {
+		return cmd.Usage
+	}
+	var sb strings.Builder
+	sb.WriteString("/" + cmd.Name)
+	for _, arg := range cmd.Args {
+		name := arg.name
+		if arg.kind == argRest {
+			name += "..."
+		}
+		if arg.optional {
+			fmt.Fprintf(&sb, " [%s]", name)
+		} else {
+			fmt.Fprintf(&sb, " <%s>", name)
+		}
+	}
+	return sb.String()
+}
+
+// usageError returns the reply to a call with wrong arguments.
+func (cmd *Command) usageError() string {
+	text := "Usage: " + cmd.usage()
+	if cmd.Example != "" {
+		text += ", e.g. " + cmd.Example
+	}
+	return text
+}
+
+// paletteDescription is the command's entry in the Telegram command palette.
+func (cmd *Command) paletteDescription() string {
+	if len(cmd.Args) == 0 {
+		return cmd.Description
+	}
+	return fmt.Sprintf("%s. Usage: %s", cmd.Description, cmd.usage())
+}
+
+// argLabel turns an argument name into words for error messages, e.g. "user_id" into "user ID".
+func argLabel(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "_id", " ID"), "_", " ")
+}
+
+// parse fills a call's arguments from the words following the command. It returns the reply to
+// send when the arguments don't fit, or "" when they do.
+func (cmd *Command) parse(c *commandCall, words []string) string {
+	c.words = make(map[string]string, len(cmd.Args))
+	c.ints = make(map[string]int64)
+	for i, arg := range cmd.Args {
+		if arg.kind == argRest {
+			if len(words) <= i {
+				if arg.optional {
+					return ""
+				}
+				return cmd.usageError()
+			}
+			c.rest = words[i:]
+			c.words[arg.name] = strings.Join(c.rest, " ")
+			return ""
+		}
+		if len(words) <= i {
+			if arg.optional {
+				return ""
+			}
+			return cmd.usageError()
+		}
+		word := words[i]
+		switch arg.kind {
+		case argInt:
+			n, err := strconv.ParseInt(word, 10, 64)
+			if err != nil {
+				return fmt.Sprintf("Invalid %s format. %s", argLabel(arg.name), cmd.usageError())
+			}
+			c.ints[arg.name] = n
+		case argLiteral:
+			if word != arg.name {
+				return "Invalid command format. " + cmd.usageError()
+			}
+		}
+		c.words[arg.name] = word
+	}
+	if len(words) > len(cmd.Args) {
+		return cmd.usageError()
+	}
+	return ""
+}
+
+// paletteCommands returns the palette entries of the commands listed in any of kinds.
+func (b *Bot) paletteCommands(kinds ...paletteKind) []models.BotCommand {
+	var entries []models.BotCommand
+	for _, kind := range kinds {
+		for _, cmd := range b.commands.All() {
+			if cmd.Palette == kind {
+				entries = append(entries, models.BotCommand{Command: cmd.Name, Description: cmd.paletteDescription()})
+			}
+		}
+	}
+	return entries
+}
+
+// userPalette returns the public and admin palette entries user can run in their private chat,
+// filtered by Scope and Visible like /help, and whether any scoped admin command is among them. The
+// user's role must have its scopes loaded.
+func (b *Bot) userPalette(ctx context.Context, user User) (entries []models.BotCommand, admin bool) {
+	c := &commandCall{message: &models.Message{
+		Chat: models.Chat{ID: user.TelegramID, Type: models.ChatTypePrivate},
+		From: &models.User{ID: user.TelegramID},
+	}}
+	for _, kind := range []paletteKind{palettePublic, paletteAdmin} {
+		for _, cmd := range b.commands.All() {
+			if cmd.Palette != kind {
+				continue
+			}
+			if cmd.Scope != "" && !b.actsAsOwner(user) && !roleHasScope(user.Role, cmd.Scope) {
+				continue
+			}
+			if cmd.Visible != nil && !cmd.Visible(b, ctx, c) {
+				continue
+			}
+			entries = append(entries, models.BotCommand{Command: cmd.Name, Description: cmd.paletteDescription()})
+			// Unscoped admin commands such as /clear_hard are open to everyone, so they alone
+			// don't call for a personal palette.
+			admin = admin || kind == paletteAdmin && cmd.Scope != ""
+		}
+	}
+	return entries, admin
+}
+
+// dispatchCommand runs the first registered command found in the message's bot_command
+// entities and reports whether the message was consumed. "/cmd@OtherBot" commands, addressed
+// to another bot in a group, are consumed silently; unknown commands are left to the model.
+func (b *Bot) dispatchCommand(ctx context.Context, message *models.Message, businessConnectionID string) bool {
+	if b.commands == nil {
+		return false
+	}
+	for _, entity := range message.Entities {
+		if entity.Type != models.MessageEntityTypeBotCommand {
+			continue
+		}
+		name := strings.TrimPrefix(strings.TrimSpace(entityText(message.Text, entity)), "/")
+		if name == "" {
+			continue
+		}
+		if base, target, addressed := strings.Cut(name, "@"); addressed {
+			me, err := b.botUser(ctx)
+			if err != nil {
+				ErrorLogger.Printf("Error resolving the bot's username: %v", err)
+				return false
+			}
+			if !strings.EqualFold(target, me.Username) {
+				return true
+			}
+			name = base
+		}
+		cmd, ok := b.commands.Get(name)
+		if !ok {
+			continue
+		}
+
+		chatID := message.Chat.ID
+		c := &commandCall{
+			message:              message,
+			businessConnectionID: businessConnectionID,
+			reply: func(text string) {
+				if err := b.sendResponse(ctx, chatID, text, businessConnectionID); err != nil {
+					ErrorLogger.Printf("Error sending response: %v", err)
+				}
+			},
+		}
+		if cmd.Scope != "" && !b.hasScope(message.From.ID, cmd.Scope) {
+			c.reply(fmt.Sprintf("Permission denied. You don't have permission to use /%s.", cmd.Name))
+			return true
+		}
+		c.args = strings.TrimSpace(textAfterEntity(message.Text, entity))
+		if problem := cmd.parse(c, strings.Fields(c.args)); problem != "" {
+			InfoLogger.Printf("User %d sent invalid arguments to /%s: %q", message.From.ID, cmd.Name, message.Text)
+			c.reply(problem)
+			return true
+		}
+		cmd.Handler(b, ctx, c)
+		return true
+	}
+	return false
+}
+
+// userRefArg is a user given as a numeric Telegram ID or as @username.
+var userRefArg = commandArg{name: "user_id|@username"}
+
+// builtinCommands returns the commands every bot handles, in palette order.
+func builtinCommands() []Command {
+	return []Command{
+		{Name: "help", Description: "Show the commands you can use", Palette: palettePublic,
+			Args:    []commandArg{{name: "command", optional: true}},
+			Handler: (*Bot).handleHelpCommand},
+		{Name: "stats", Description: "Get bot statistics", Palette: palettePublic,
+			Args:    []commandArg{{name: "user", kind: argLiteral, optional: true}, {name: "user_id", kind: argInt, optional: true}},
+			Usage:   "/stats or /stats user [user_id]",
+			Handler: (*Bot).handleStatsCommand},
+		{Name: "whoami", Description: "Get your user information", Palette: palettePublic,
+			Handler: (*Bot).handleWhoAmICommand},
+		{Name: "clear", Description: "Clear chat history (soft delete); admins can name a user and chat", Palette: palettePublic,
+			Args:    []commandArg{{name: "user_id", kind: argInt, optional: true}, {name: "chat_id", kind: argInt, optional: true}},
+			Handler: (*Bot).handleClearCommand},
+		{Name: "summary", Description: "Show the summary of the earlier conversation", Palette: palettePublic,
+			Handler: (*Bot).handleSummaryCommand},
+		{Name: "persona", Description: "List the personas or switch this chat to one", Palette: palettePublic,
+			Args:    []commandArg{{name: "name", optional: true}},
+			Example: "/persona pirate",
+			Handler: (*Bot).handlePersonaCommand},
+		{Name: "instructions", Description: "Show, set or clear your own instructions for the bot", Palette: palettePublic,
+			Args:    []commandArg{{name: "show|set|clear"}, {name: "text|user_id", kind: argRest, optional: true}},
+			Usage:   "/instructions show|clear [user_id] or /instructions set <text>",
+			Example: "/instructions set Answer briefly and in English",
+			Handler: (*Bot).handleInstructionsCommand},
+		{Name: "limits", Description: "Show your rate limits and remaining messages; admins can name a user", Palette: palettePublic,
+			Args:    []commandArg{{name: "user_id", kind: argInt, optional: true}},
+			Handler: (*Bot).handleLimitsCommand},
+
+		{Name: "clear_hard", Description: "Clear chat history (permanently delete); admins can name a user and chat", Palette: paletteAdmin,
+			Args:    []commandArg{{name: "user_id", kind: argInt, optional: true}, {name: "chat_id", kind: argInt, optional: true}},
+			Handler: (*Bot).handleClearHardCommand},
+		{Name: "set_model", Description: "Switch the AI model", Palette: paletteAdmin, Scope: ScopeModelSet,
+			Args:    []commandArg{{name: "model-id"}},
+			Handler: (*Bot).handleSetModelCommand},
+		{Name: "ban", Description: "Ban a user", Palette: paletteAdmin, Scope: ScopeUserBan,
+			Args:    []commandArg{{name: "user_id", kind: argInt}, {name: "duration|permanent"}},
+			Example: "/ban 12345 12h or /ban 12345 7d",
+			Handler: (*Bot).handleBanCommand},
+		{Name: "unban", Description: "Lift a user's ban and reset their rate limits", Palette: paletteAdmin, Scope: ScopeUserBan,
+			Args:    []commandArg{{name: "user_id", kind: argInt}},
+			Handler: (*Bot).handleUnbanCommand},
+		{Name: "promote", Description: "Give a user a role, admin by default", Palette: paletteAdmin, Scope: ScopeUserPromote,
+			Args:    []commandArg{userRefArg, {name: "role", optional: true}},
+			Handler: (*Bot).handlePromoteCommand},
+		{Name: "demote", Description: "Reset a user to the user role", Palette: paletteAdmin, Scope: ScopeUserDemote,
+			Args:    []commandArg{userRefArg},
+			Handler: (*Bot).handleDemoteCommand},
+		{Name: "users", Description: "List users and their roles", Palette: paletteAdmin, Scope: ScopeUserList,
+			Args:    []commandArg{{name: "role", optional: true}},
+			Handler: (*Bot).handleUsersCommand},
+		{Name: "roles", Description: "List roles and their scopes", Palette: paletteAdmin, Scope: ScopeRoleView,
+			Handler: (*Bot).handleRolesCommand},
+		{Name: "role_create", Description: "Create a custom role", Palette: paletteAdmin, Scope: ScopeRoleManage,
+			Args:    []commandArg{{name: "name"}, {name: "scope", kind: argRest, optional: true}},
+			Handler: (*Bot).handleRoleCreateCommand},
+		{Name: "role_delete", Description: "Delete an unused custom role", Palette: paletteAdmin, Scope: ScopeRoleManage,
+			Args:    []commandArg{{name: "name"}},
+			Handler: (*Bot).handleRoleDeleteCommand},
+		{Name: "role_grant", Description: "Grant scopes to a role", Palette: paletteAdmin, Scope: ScopeRoleManage,
+			Args:    []commandArg{{name: "role"}, {name: "scope", kind: argRest}},
+			Handler: (*Bot).handleRoleGrantCommand},
+		{Name: "role_revoke", Description: "Revoke scopes from a role", Palette: paletteAdmin, Scope: ScopeRoleManage,
+			Args:    []commandArg{{name: "role"}, {name: "scope", kind: argRest}},
+			Handler: (*Bot).handleRoleRevokeCommand},
+		{Name: "transfer_owner", Description: "Hand the bot over to another user (owner only)", Palette: paletteAdmin,
+			Args:    []commandArg{{name: "user_id|accept|confirm|cancel"}},
+			Handler: (*Bot).handleTransferOwnerCommand,
+			Visible: func(b *Bot, _ context.Context, c *commandCall) bool { return b.ownerTelegramID() == c.userID() }},
+		{Name: "access", Description: "Show the access mode, allow/deny lists and invite codes", Palette: paletteAdmin, Scope: ScopeUserAccess,
+			Handler: (*Bot).handleAccessCommand},
+		{Name: "allow", Description: "Add a user to the allowlist", Palette: paletteAdmin, Scope: ScopeUserAccess,
+			Args:    []commandArg{userRefArg},
+			Handler: (*Bot).handleAllowCommand},
+		{Name: "disallow", Description: "Remove a user from the allowlist", Palette: paletteAdmin, Scope: ScopeUserAccess,
+			Args:    []commandArg{userRefArg},
+			Handler: (*Bot).handleDisallowCommand},
+		{Name: "deny", Description: "Silently ignore a user", Palette: paletteAdmin, Scope: ScopeUserAccess,
+			Args:    []commandArg{userRefArg},
+			Handler: (*Bot).handleDenyCommand},
+		{Name: "undeny", Description: "Remove a user from the denylist", Palette: paletteAdmin, Scope: ScopeUserAccess,
+			Args:    []commandArg{userRefArg},
+			Handler: (*Bot).handleUndenyCommand},
+		{Name: "invite", Description: "Create an invite link", Palette: paletteAdmin, Scope: ScopeUserAccess,
+			Args:    []commandArg{{name: "uses", kind: argInt, optional: true}, {name: "expiry", optional: true}},
+			Example: "/invite 5 7d",
+			Handler: (*Bot).handleInviteCommand},
+
+		{Name: "group", Description: "Show or change how the bot behaves in this group", Palette: paletteGroupAdmin,
+			Args:    []commandArg{{name: "setting", optional: true}, {name: "value", optional: true}},
+			Usage:   "/group, /group on|off, /group trigger mention|keyword|all or /group topics all|<id>[,<id>...]",
+			Example: "/group trigger keyword",
+			Handler: (*Bot).handleGroupCommand,
+			Visible: func(b *Bot, ctx context.Context, c *commandCall) bool {
+				return isGroupChat(c.message.Chat) && b.isGroupAdmin(ctx, c.chatID(), c.userID())
+			}},
+	}
+}
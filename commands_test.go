@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCommandRegistry_Register verifies name validation, duplicate rejection, argument checks and ordering.
+func TestCommandRegistry_Register(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	noop := func(*Bot, context.Context, *commandCall) {}
+	r := newCommandRegistry()
+
+	assert.NoError(t, r.Register(Command{Name: "first", Handler: noop}))
+	assert.NoError(t, r.Register(Command{Name: "second", Handler: noop}))
+	assert.Error(t, r.Register(Command{Name: "first", Handler: noop}), "duplicate name")
+	assert.Error(t, r.Register(Command{Name: "Upper", Handler: noop}), "invalid name")
+	assert.Error(t, r.Register(Command{Name: "no_handler"}), "missing Handler")
+	assert.Error(t, r.Register(Command{Name: "rest_first", Handler: noop,
+		Args: []commandArg{{name: "words", kind: argRest}, {name: "last"}}}), "argRest not last")
+
+	var names []string
+	for _, cmd := range r.All() {
+		names = append(names, cmd.Name)
+	}
+	assert.Equal(t, []string{"first", "second"}, names)
+
+	// Every built-in command registers cleanly.
+	builtin := newCommandRegistry()
+	for _, cmd := range builtinCommands() {
+		assert.NoError(t, builtin.Register(cmd))
+	}
+}
+
+// TestCommandParse verifies the generated usage and the errors for missing, malformed and extra arguments.
+func TestCommandParse(t *testing.T) {
+	cmd := &Command{Name: "clear", Args: []commandArg{
+		{name: "user_id", kind: argInt, optional: true},
+		{name: "chat_id", kind: argInt, optional: true},
+	}}
+	assert.Equal(t, "/clear [user_id] [chat_id]", cmd.usage())
+
+	c := &commandCall{}
+	assert.Empty(t, cmd.parse(c, nil))
+	assert.False(t, c.has("user_id"))
+
+	c = &commandCall{}
+	assert.Empty(t, cmd.parse(c, []string{"12", "-34"}))
+	assert.Equal(t, int64(12), c.int("user_id"))
+	assert.Equal(t, int64(-34), c.int("chat_id"))
+
+	assert.Equal(t, "Invalid chat ID format. Usage: /clear [user_id] [chat_id]", cmd.parse(&commandCall{}, []string{"12", "abc"}))
+	assert.Equal(t, "Usage: /clear [user_id] [chat_id]", cmd.parse(&commandCall{}, []string{"1", "2", "3"}))
+
+	ban := &Command{Name: "ban", Example: "/ban 12345 7d",
+		Args: []commandArg{{name: "user_id", kind: argInt}, {name: "duration|permanent"}}}
+	assert.Equal(t, "Usage: /ban <user_id> <duration|permanent>, e.g. /ban 12345 7d", ban.parse(&commandCall{}, []string{"12345"}))
+
+	grant := &Command{Name: "role_grant", Args: []commandArg{{name: "role"}, {name: "scope", kind: argRest}}}
+	assert.Equal(t, "/role_grant <role> <scope...>", grant.usage())
+	assert.NotEmpty(t, grant.parse(&commandCall{}, []string{"vip"}))
+	c = &commandCall{}
+	assert.Empty(t, grant.parse(c, []string{"vip", "model:set", "user:ban"}))
+	assert.Equal(t, "vip", c.str("role"))
+	assert.Equal(t, []string{"model:set", "user:ban"}, c.rest)
+}
+
+// TestDispatchCommand verifies /cmd@BotName handling, scope checks, UTF-16 entity offsets and that
+// unknown commands fall through.
+func TestDispatchCommand(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	var sent []string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		sent = append(sent, params.Text)
+		return &models.Message{}, nil
+	}
+	dispatch := func(userID int64, text string) bool {
+		sent = nil
+		return b.dispatchCommand(context.Background(), commandUpdate(userID, -100, text).Message, "")
+	}
+
+	// Addressed to this bot: handled, with the arguments after the mention.
+	assert.True(t, dispatch(123, "/limits@test_bot 456"))
+	if assert.Len(t, sent, 1) {
+		assert.Contains(t, sent[0], "456")
+	}
+	assert.True(t, dispatch(123, "/whoami@Test_Bot"))
+	assert.Len(t, sent, 1)
+
+	// Addressed to another bot: consumed without a reply.
+	assert.True(t, dispatch(123, "/whoami@other_bot"))
+	assert.Empty(t, sent)
+
+	// Unknown commands are left to the model.
+	assert.False(t, dispatch(123, "/unknown"))
+	assert.Empty(t, sent)
+
+	// The scope is checked before the arguments.
+	assert.True(t, dispatch(789, "/ban"))
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, "Permission denied. You don't have permission to use /ban.", sent[0])
+	}
+	assert.True(t, dispatch(123, "/ban abc 7d"))
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, "Invalid user ID format. Usage: /ban <user_id> <duration|permanent>, e.g. /ban 12345 12h or /ban 12345 7d", sent[0])
+	}
+
+	// Entity offsets are UTF-16 code units, so text before the command can't shift it.
+	sent = nil
+	msg := commandUpdate(123, -100, "🙂 /limits 456").Message
+	msg.Entities = []models.MessageEntity{{Type: models.MessageEntityTypeBotCommand, Offset: 3, Length: len("/limits")}}
+	assert.True(t, b.dispatchCommand(context.Background(), msg, ""))
+	if assert.Len(t, sent, 1) {
+		assert.Contains(t, sent[0], "Limits for user 456:")
+	}
+}
+
+// TestPaletteCommands verifies that the palettes are generated from the registry.
+func TestPaletteCommands(t *testing.T) {
+	b, _ := setupBotForTest(t, 123)
+
+	names := func(entries []models.BotCommand) []string {
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Command)
+		}
+		return out
+	}
+	public := names(b.paletteCommands(palettePublic))
+	assert.Equal(t, []string{"help", "stats", "whoami", "clear", "summary", "persona", "instructions", "limits"}, public)
+
+	admin := names(b.paletteCommands(palettePublic, paletteAdmin))
+	assert.Equal(t, public, admin[:len(public)])
+	assert.Contains(t, admin, "ban")
+	assert.NotContains(t, admin, "group")
+
+	for _, e := range b.paletteCommands(paletteAdmin) {
+		if e.Command == "unban" {
+			assert.Equal(t, "Lift a user's ban and reset their rate limits. Usage: /unban <user_id>", e.Description)
+		}
+	}
+	assert.Equal(t, []string{"group"}, names(b.paletteCommands(paletteGroupAdmin)))
+}
+
+// TestUserPalette verifies that personal palettes only list the commands the user's scopes allow.
+func TestUserPalette(t *testing.T) {
+	b, _ := setupBotForTest(t, 123)
+	_, err := b.getOrCreateUser(456, "helper", false)
+	assert.NoError(t, err)
+	assert.NoError(t, b.promoteUserToAdmin(123, 456))
+	_, err = b.getOrCreateUser(789, "regular", false)
+	assert.NoError(t, err)
+
+	palette := func(telegramID int64) ([]string, bool) {
+		u, err := b.loadUser(telegramID)
+		assert.NoError(t, err)
+		entries, admin := b.userPalette(context.Background(), u)
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Command)
+		}
+		return names, admin
+	}
+
+	admin, elevated := palette(456)
+	assert.True(t, elevated)
+	assert.Contains(t, admin, "ban")
+	assert.Contains(t, admin, "access")
+	assert.NotContains(t, admin, "role_create")
+	assert.NotContains(t, admin, "role_grant")
+	assert.NotContains(t, admin, "transfer_owner")
+
+	owner, elevated := palette(123)
+	assert.True(t, elevated)
+	assert.Contains(t, owner, "role_create")
+	assert.Contains(t, owner, "transfer_owner")
+
+	_, elevated = palette(789)
+	assert.False(t, elevated, "regular users keep the default palette")
+}
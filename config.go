@@ -3,31 +3,74 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/liushuangls/go-anthropic/v2"
 )
 
 type BotConfig struct {
-	ID                string            `json:"id"`
-	TelegramToken     string            `json:"telegram_token"`
-	MemorySize        int               `json:"memory_size"`
-	MessagePerHour    int               `json:"messages_per_hour"`
-	MessagePerDay     int               `json:"messages_per_day"`
-	TempBanDuration   string            `json:"temp_ban_duration"`
-	Model             anthropic.Model   `json:"model"`
-	Temperature       *float32          `json:"temperature,omitempty"` // Controls creativity vs determinism (0.0-1.0)
-	SystemPrompts     map[string]string `json:"system_prompts"`
-	Active            bool              `json:"active"`
-	OwnerTelegramID   int64             `json:"owner_telegram_id"`
-	AnthropicAPIKey   string            `json:"anthropic_api_key"`
-	ElevenLabsAPIKey  string            `json:"elevenlabs_api_key"`
+	ID                 string                   `json:"id"`
+	TelegramToken      string                   `json:"telegram_token"`
+	MemorySize         int                      `json:"memory_size"`
+	MessagePerHour     int                      `json:"messages_per_hour"`
+	MessagePerDay      int                      `json:"messages_per_day"`
+	TempBanDuration    string                   `json:"temp_ban_duration"`
+	Model              anthropic.Model          `json:"model"`
+	Temperature        *float32                 `json:"temperature,omitempty"` // Controls creativity vs determinism (0.0-1.0)
+	SystemPrompts      map[string]string        `json:"system_prompts"`        // Go templates; see the README for the variables
+	PromptVars         map[string]string        `json:"prompt_vars"`           // Custom values for the prompts, used as {{.Vars.name}}
+	Timezone           string                   `json:"timezone"`              // IANA name for the dates and times in prompts, e.g. "Europe/Berlin"; defaults to the server's
+	Personas           map[string]Persona       `json:"personas"`              // Named personalities chats can switch to with /persona
+	DefaultPersona     string                   `json:"default_persona"`       // Persona of chats that haven't picked one; empty uses the settings above
+	Active             bool                     `json:"active"`
+	OwnerTelegramID    int64                    `json:"owner_telegram_id"`
+	CoOwnerTelegramIDs []int64                  `json:"co_owner_telegram_ids"` // Users holding every scope besides the owner; they can't remove the owner
+	AccessMode         string                   `json:"access_mode"`           // "open" (default), "allowlist" or "invite"
+	Provider           string                   `json:"provider"`              // "anthropic" (default) or "openai"
+	AnthropicAPIKey    string                   `json:"anthropic_api_key"`
+	OpenAIBaseURL      string                   `json:"openai_base_url"` // Any OpenAI-compatible endpoint; defaults to api.openai.com
+	OpenAIAPIKey       string                   `json:"openai_api_key"`
+	ElevenLabsAPIKey   string                   `json:"elevenlabs_api_key"`
+	ElevenLabsVoiceID  string                   `json:"elevenlabs_voice_id"`
+	ElevenLabsModel    string                   `json:"elevenlabs_model"`
+	ContextTokenBudget int                      `json:"context_token_budget"` // Max estimated tokens of chat history sent per request; 0 keeps the memory_size window
+	ExactTokenCount    bool                     `json:"exact_token_count"`    // Verify the budget with the count-tokens API (anthropic only)
+	MaxOutputTokens    int                      `json:"max_output_tokens"`    // Max tokens per reply; defaults to 1000
+	MaxInstructions    int                      `json:"max_instructions"`     // Max characters of a user's /instructions; defaults to 1000
+	Prices             map[string]ModelPrice    `json:"prices"`               // USD per million tokens by model, used to estimate spend
+	RateLimits         map[string]RateLimitTier `json:"rate_limits"`          // Message limits per role name, e.g. "premium"
+	RateLimitPolicy    RateLimitPolicy          `json:"rate_limit_policy"`    // How repeated violations escalate to temp bans
+	UserRateLimits     map[int64]RateLimitTier  `json:"user_rate_limits"`     // Message limits per Telegram user ID; override the role tier
+	Quotas             QuotaConfig              `json:"quotas"`               // Token and spend limits per user, role and bot
+	SummarizeHistory   bool                     `json:"summarize_history"`    // Fold messages leaving memory into a rolling per-chat summary
+	GroupKeywords      []string                 `json:"group_keywords"`       // Words that address the bot in groups using the "keyword" trigger
+	MaxImageSize       int64                    `json:"max_image_size"`       // Per-image limit in bytes for vision; defaults to 5 MB
+	EnableTools        bool                     `json:"enable_tools"`         // Let the model call built-in tools (takes precedence over streaming)
+	DebugScreening     bool                     `json:"debug_screening"`      // Enable detailed screening logs
+	StreamResponses    bool                     `json:"stream_responses"`     // Progressively edit the reply while the model is generating
+	StreamEditInterval string                   `json:"stream_edit_interval"` // Minimum time between edits while streaming, e.g. "1.5s"
+	Transport          string                   `json:"transport"`            // "polling" (default) or "webhook"
+	WebhookListenAddr  string                   `json:"webhook_listen_addr"`  // Local address of the shared webhook server, e.g. ":8080"
+	WebhookPublicURL   string                   `json:"webhook_public_url"`   // Public HTTPS base URL Telegram posts to, e.g. "https://bots.example.com"
+	WebhookPath        string                   `json:"webhook_path"`         // Route on the shared server; defaults to "/<id>"
+	WebhookSecretToken string                   `json:"webhook_secret_token"` // Verified against the X-Telegram-Bot-Api-Secret-Token header
+	ConfigFilePath     string                   `json:"-"`                    // Set at load time; not serialized
+}
+
+// Persona is a named personality a chat can switch to with /persona. Unset fields fall back to
+// the bot's own settings.
+type Persona struct {
+	Description       string            `json:"description"`    // Shown in the /persona list
+	SystemPrompts     map[string]string `json:"system_prompts"` // Replace the bot's system_prompts key by key
+	Model             string            `json:"model"`
+	Temperature       *float32          `json:"temperature,omitempty"`
 	ElevenLabsVoiceID string            `json:"elevenlabs_voice_id"`
-	ElevenLabsModel   string            `json:"elevenlabs_model"`
-	DebugScreening    bool              `json:"debug_screening"` // Enable detailed screening logs
-	ConfigFilePath    string            `json:"-"`               // Set at load time; not serialized
 }
 
 // Custom unmarshalling to handle anthropic.Model
@@ -82,6 +125,7 @@ func loadAllConfigs(dir string) ([]BotConfig, error) {
 	var configs []BotConfig
 	ids := make(map[string]bool)
 	tokens := make(map[string]bool)
+	webhookRoutes := make(map[string]bool) // listen address + path
 
 	files, err := os.ReadDir(dir)
 	if err != nil {
@@ -112,6 +156,15 @@ func loadAllConfigs(dir string) ([]BotConfig, error) {
 				continue
 			}
 
+			if config.Transport == TransportWebhook {
+				route := config.WebhookListenAddr + config.webhookPath()
+				if webhookRoutes[route] {
+					InfoLogger.Printf("Config validation failed for %s: duplicate webhook path %s on %s", validPath, config.webhookPath(), config.WebhookListenAddr)
+					continue
+				}
+				webhookRoutes[route] = true
+			}
+
 			config.ConfigFilePath = validPath
 			configs = append(configs, config)
 		}
@@ -145,6 +198,119 @@ func validateConfig(config *BotConfig, ids, tokens map[string]bool) error {
 		return fmt.Errorf("missing 'model' field")
 	}
 
+	switch config.Provider {
+	case "", ProviderAnthropic, ProviderOpenAI:
+	default:
+		return fmt.Errorf("unknown 'provider' %q (expected %q or %q)", config.Provider, ProviderAnthropic, ProviderOpenAI)
+	}
+
+	switch config.AccessMode {
+	case "", AccessModeOpen, AccessModeAllowlist, AccessModeInvite:
+	default:
+		return fmt.Errorf("unknown 'access_mode' %q (expected %q, %q or %q)", config.AccessMode, AccessModeOpen, AccessModeAllowlist, AccessModeInvite)
+	}
+
+	for i, id := range config.CoOwnerTelegramIDs {
+		if id == 0 || id == config.OwnerTelegramID || slices.Contains(config.CoOwnerTelegramIDs[:i], id) {
+			return fmt.Errorf("invalid co-owner %d: co_owner_telegram_ids must hold distinct non-zero IDs other than the owner", id)
+		}
+	}
+
+	if config.Timezone != "" {
+		if _, err := time.LoadLocation(config.Timezone); err != nil {
+			return fmt.Errorf("invalid 'timezone' %q: %w", config.Timezone, err)
+		}
+	}
+	for name := range config.PromptVars {
+		if !promptVarPattern.MatchString(name) {
+			return fmt.Errorf("invalid prompt_vars name %q: use letters, digits and '_', not starting with a digit", name)
+		}
+	}
+	for key, text := range config.SystemPrompts {
+		if err := validatePrompt(key, text, config.PromptVars); err != nil {
+			return fmt.Errorf("invalid system prompt %q: %w", key, err)
+		}
+	}
+	for name, persona := range config.Personas {
+		for key, text := range persona.SystemPrompts {
+			if err := validatePrompt(key, text, config.PromptVars); err != nil {
+				return fmt.Errorf("invalid system prompt %q of persona %q: %w", key, name, err)
+			}
+		}
+		if !roleNamePattern.MatchString(name) || name == personaDefault {
+			return fmt.Errorf("invalid persona name %q: use up to 32 lowercase letters, digits, '_' or '-', starting with a letter, other than %q", name, personaDefault)
+		}
+		if t := persona.Temperature; t != nil && (*t < 0 || *t > 1) {
+			return fmt.Errorf("temperature of persona %q must be between 0 and 1", name)
+		}
+	}
+	if _, ok := config.Personas[config.DefaultPersona]; config.DefaultPersona != "" && !ok {
+		return fmt.Errorf("'default_persona' %q is not one of the personas", config.DefaultPersona)
+	}
+
+	if config.StreamEditInterval != "" {
+		if d, err := time.ParseDuration(config.StreamEditInterval); err != nil || d <= 0 {
+			return fmt.Errorf("invalid 'stream_edit_interval' %q", config.StreamEditInterval)
+		}
+	}
+
+	if config.ContextTokenBudget < 0 {
+		return fmt.Errorf("'context_token_budget' must not be negative")
+	}
+	if config.MaxOutputTokens < 0 {
+		return fmt.Errorf("'max_output_tokens' must not be negative")
+	}
+	if config.MaxInstructions < 0 {
+		return fmt.Errorf("'max_instructions' must not be negative")
+	}
+	if config.ExactTokenCount && config.Provider == ProviderOpenAI {
+		return fmt.Errorf("'exact_token_count' requires the %q provider", ProviderAnthropic)
+	}
+
+	for model, price := range config.Prices {
+		if price.Input < 0 || price.Output < 0 || price.CacheWrite < 0 || price.CacheRead < 0 {
+			return fmt.Errorf("prices for model %q must not be negative", model)
+		}
+	}
+
+	for role, tier := range config.RateLimits {
+		if tier.MessagesPerHour < 0 || tier.MessagesPerDay < 0 {
+			return fmt.Errorf("rate limits for role %q must not be negative", role)
+		}
+	}
+	for userID, tier := range config.UserRateLimits {
+		if tier.MessagesPerHour < 0 || tier.MessagesPerDay < 0 {
+			return fmt.Errorf("rate limits for user %d must not be negative", userID)
+		}
+	}
+
+	if err := config.RateLimitPolicy.validate(); err != nil {
+		return fmt.Errorf("invalid rate_limit_policy: %w", err)
+	}
+
+	if err := config.Quotas.User.validate(); err != nil {
+		return fmt.Errorf("invalid user quota: %w", err)
+	}
+	if err := config.Quotas.Bot.validate(); err != nil {
+		return fmt.Errorf("invalid bot quota: %w", err)
+	}
+	for role, quota := range config.Quotas.Roles {
+		if err := quota.validate(); err != nil {
+			return fmt.Errorf("invalid quota for role %q: %w", role, err)
+		}
+	}
+	if t := config.Quotas.AlertThreshold; t < 0 || t > 1 {
+		return fmt.Errorf("'alert_threshold' must be between 0 and 1")
+	}
+
+	if config.MaxImageSize < 0 {
+		return fmt.Errorf("'max_image_size' must not be negative")
+	}
+
+	if err := validateTransport(config); err != nil {
+		return err
+	}
+
 	if config.MessagePerHour <= 0 {
 		return fmt.Errorf("'messages_per_hour' must be greater than 0")
 	}
@@ -156,6 +322,38 @@ func validateConfig(config *BotConfig, ids, tokens map[string]bool) error {
 	return nil
 }
 
+// webhookSecretPattern mirrors Telegram's allowed secret_token charset (1-256 chars).
+var webhookSecretPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// validateTransport checks the polling/webhook settings of a single bot.
+func validateTransport(config *BotConfig) error {
+	switch config.Transport {
+	case "", TransportPolling:
+		return nil
+	case TransportWebhook:
+	default:
+		return fmt.Errorf("unknown 'transport' %q (expected %q or %q)", config.Transport, TransportPolling, TransportWebhook)
+	}
+
+	if config.WebhookListenAddr == "" {
+		return fmt.Errorf("missing 'webhook_listen_addr' field for webhook transport")
+	}
+	publicURL, err := url.Parse(config.WebhookPublicURL)
+	if err != nil || publicURL.Scheme != "https" || publicURL.Host == "" {
+		return fmt.Errorf("'webhook_public_url' must be an absolute https URL")
+	}
+	if config.WebhookPath != "" && !strings.HasPrefix(config.WebhookPath, "/") {
+		return fmt.Errorf("'webhook_path' must start with '/'")
+	}
+	if config.WebhookSecretToken == "" {
+		return fmt.Errorf("missing 'webhook_secret_token' field for webhook transport")
+	}
+	if !webhookSecretPattern.MatchString(config.WebhookSecretToken) {
+		return fmt.Errorf("'webhook_secret_token' may only contain A-Z, a-z, 0-9, '_' and '-' (max 256 chars)")
+	}
+	return nil
+}
+
 func loadConfig(filename string) (BotConfig, error) {
 	var config BotConfig
 	// Use filepath.Clean before opening the file
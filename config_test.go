@@ -333,6 +333,234 @@ func TestValidateConfig(t *testing.T) {
 			wantErr:       true,
 			expectedError: "'messages_per_day' must be greater than 0",
 		},
+		{
+			name: "OpenAI Provider",
+			config: BotConfig{
+				ID:             "bot123",
+				TelegramToken:  "token123",
+				Model:          "gpt-4o-mini",
+				Provider:       "openai",
+				MessagePerHour: 10,
+				MessagePerDay:  100,
+			},
+			ids:     make(map[string]bool),
+			tokens:  make(map[string]bool),
+			wantErr: false,
+		},
+		{
+			name: "Unknown Provider",
+			config: BotConfig{
+				ID:             "bot123",
+				TelegramToken:  "token123",
+				Model:          "claude-v1",
+				Provider:       "bogus",
+				MessagePerHour: 10,
+				MessagePerDay:  100,
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "unknown 'provider'",
+		},
+		{
+			name: "Negative Context Token Budget",
+			config: BotConfig{
+				ID:                 "bot123",
+				TelegramToken:      "token123",
+				Model:              "claude-v1",
+				MessagePerHour:     10,
+				MessagePerDay:      100,
+				ContextTokenBudget: -1,
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "'context_token_budget' must not be negative",
+		},
+		{
+			name: "Exact Token Count With OpenAI",
+			config: BotConfig{
+				ID:                 "bot123",
+				TelegramToken:      "token123",
+				Model:              "gpt-4o-mini",
+				Provider:           "openai",
+				MessagePerHour:     10,
+				MessagePerDay:      100,
+				ContextTokenBudget: 2000,
+				ExactTokenCount:    true,
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "'exact_token_count' requires",
+		},
+		{
+			name: "Negative Price",
+			config: BotConfig{
+				ID:             "bot123",
+				TelegramToken:  "token123",
+				Model:          "claude-v1",
+				MessagePerHour: 10,
+				MessagePerDay:  100,
+				Prices:         map[string]ModelPrice{"claude-v1": {Input: 1, Output: -5}},
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "must not be negative",
+		},
+		{
+			name: "Invalid Ban Ladder",
+			config: BotConfig{
+				ID:              "bot123",
+				TelegramToken:   "token123",
+				Model:           "claude-v1",
+				MessagePerHour:  10,
+				MessagePerDay:   100,
+				RateLimitPolicy: RateLimitPolicy{BanLadder: []string{"10m", "forever"}},
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "invalid 'ban_ladder' step",
+		},
+		{
+			name: "Invalid Quota Alert Threshold",
+			config: BotConfig{
+				ID:             "bot123",
+				TelegramToken:  "token123",
+				Model:          "claude-v1",
+				MessagePerHour: 10,
+				MessagePerDay:  100,
+				Quotas:         QuotaConfig{Bot: Quota{DailyCost: 5}, AlertThreshold: 80},
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "'alert_threshold' must be between 0 and 1",
+		},
+		{
+			name: "Owner Listed As Co-Owner",
+			config: BotConfig{
+				ID:                 "bot123",
+				TelegramToken:      "token123",
+				Model:              "claude-v1",
+				OwnerTelegramID:    123456789,
+				CoOwnerTelegramIDs: []int64{555, 123456789},
+				MessagePerHour:     10,
+				MessagePerDay:      100,
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "invalid co-owner 123456789",
+		},
+		{
+			name: "Unknown Access Mode",
+			config: BotConfig{
+				ID:             "bot123",
+				TelegramToken:  "token123",
+				Model:          "claude-v1",
+				AccessMode:     "private",
+				MessagePerHour: 10,
+				MessagePerDay:  100,
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "unknown 'access_mode'",
+		},
+		{
+			name: "Persona Named Default",
+			config: BotConfig{
+				ID:             "bot123",
+				TelegramToken:  "token123",
+				Model:          "claude-v1",
+				Personas:       map[string]Persona{"default": {}},
+				MessagePerHour: 10,
+				MessagePerDay:  100,
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "invalid persona name",
+		},
+		{
+			name: "Unknown Default Persona",
+			config: BotConfig{
+				ID:             "bot123",
+				TelegramToken:  "token123",
+				Model:          "claude-v1",
+				Personas:       map[string]Persona{"pirate": {}},
+				DefaultPersona: "butler",
+				MessagePerHour: 10,
+				MessagePerDay:  100,
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "'default_persona' \"butler\"",
+		},
+		{
+			name: "Unknown Timezone",
+			config: BotConfig{
+				ID:             "bot123",
+				TelegramToken:  "token123",
+				Model:          "claude-v1",
+				Timezone:       "Mars/Olympus_Mons",
+				MessagePerHour: 10,
+				MessagePerDay:  100,
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "invalid 'timezone'",
+		},
+		{
+			name: "Invalid Prompt Var Name",
+			config: BotConfig{
+				ID:             "bot123",
+				TelegramToken:  "token123",
+				Model:          "claude-v1",
+				PromptVars:     map[string]string{"sales-email": "sales@example.com"},
+				MessagePerHour: 10,
+				MessagePerDay:  100,
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "invalid prompt_vars name \"sales-email\"",
+		},
+		{
+			name: "Unknown Prompt Variable",
+			config: BotConfig{
+				ID:             "bot123",
+				TelegramToken:  "token123",
+				Model:          "claude-v1",
+				SystemPrompts:  map[string]string{"default": "{{if .Premium}}Hi {{.Firstname}}{{end}}"},
+				MessagePerHour: 10,
+				MessagePerDay:  100,
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "invalid system prompt \"default\": unknown variable .Firstname",
+		},
+		{
+			name: "Invalid Persona Prompt",
+			config: BotConfig{
+				ID:             "bot123",
+				TelegramToken:  "token123",
+				Model:          "claude-v1",
+				Personas:       map[string]Persona{"pirate": {SystemPrompts: map[string]string{"default": "{{.Vars.ship}}"}}},
+				MessagePerHour: 10,
+				MessagePerDay:  100,
+			},
+			ids:           make(map[string]bool),
+			tokens:        make(map[string]bool),
+			wantErr:       true,
+			expectedError: "invalid system prompt \"default\" of persona \"pirate\"",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+
+	"github.com/liushuangls/go-anthropic/v2"
+)
+
+const (
+	// defaultMaxOutputTokens is the reply length limit when max_output_tokens is not set.
+	defaultMaxOutputTokens = 1000
+	// budgetMemoryMessages is how many messages chat memory holds when a token budget is set;
+	// the budget, not the count, then decides how many of them are sent.
+	budgetMemoryMessages = 200
+	// messageTokenOverhead approximates the per-turn framing tokens.
+	messageTokenOverhead = 4
+	// imageTokenEstimate approximates a downscaled image (~1.15 megapixels) as counted by Anthropic.
+	imageTokenEstimate = 1600
+	// maxBudgetFitRounds bounds the count-tokens calls made per request.
+	maxBudgetFitRounds = 3
+)
+
+// maxOutputTokens returns the configured reply length limit.
+func (b *Bot) maxOutputTokens() int {
+	if b.config.MaxOutputTokens > 0 {
+		return b.config.MaxOutputTokens
+	}
+	return defaultMaxOutputTokens
+}
+
+// memoryCapacity is the number of messages kept in chat memory.
+func (b *Bot) memoryCapacity() int {
+	if b.config.ContextTokenBudget > 0 {
+		return budgetMemoryMessages
+	}
+	return b.memorySize * 2
+}
+
+// estimateTokens approximates the token count of text (about four bytes per token; multi-byte
+// scripts are counted closer to one token per character, which errs on the safe side).
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// estimateMessageTokens approximates the tokens a stored message adds to the context.
+func (b *Bot) estimateMessageTokens(msg Message) int {
+	tokens := messageTokenOverhead + estimateTokens(msg.Text)
+	if _, ok := b.imageContent(msg); ok {
+		tokens += imageTokenEstimate
+	}
+	return tokens
+}
+
+// estimateContentTokens approximates the tokens of a message already in request form.
+func estimateContentTokens(msg anthropic.Message) int {
+	tokens := messageTokenOverhead
+	for _, content := range msg.Content {
+		switch content.Type {
+		case anthropic.MessagesContentTypeText:
+			tokens += estimateTokens(content.GetText())
+		case anthropic.MessagesContentTypeImage:
+			tokens += imageTokenEstimate
+		}
+	}
+	return tokens
+}
+
+// contextWindow returns the newest messages of the chat memory that fit the token budget, oldest
+// first. The newest message is always included; when trimming, the window starts at a user turn.
+// Without a budget the whole memory is returned. The caller must hold chatMemoriesMu.
+func (b *Bot) contextWindow(chatMemory *ChatMemory) []Message {
+	budget := b.config.ContextTokenBudget
+	if budget <= 0 || len(chatMemory.Messages) == 0 {
+		return chatMemory.Messages
+	}
+
+	start := len(chatMemory.Messages) - 1
+	used := b.estimateMessageTokens(chatMemory.Messages[start])
+	for start > 0 {
+		cost := b.estimateMessageTokens(chatMemory.Messages[start-1])
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		start--
+	}
+	if start > 0 {
+		for start < len(chatMemory.Messages)-1 && !chatMemory.Messages[start].IsUser {
+			start++
+		}
+	}
+	return chatMemory.Messages[start:]
+}
+
+// fitRequestToBudget verifies the history of request against the token budget with the provider's
+// exact counter (when exact_token_count is on) and drops the oldest turns until it fits.
+// Counting failures are logged and leave the locally estimated context unchanged.
+func (b *Bot) fitRequestToBudget(ctx context.Context, request *CompletionRequest) {
+	budget := b.config.ContextTokenBudget
+	counter, ok := b.llm.(TokenCounter)
+	if !b.config.ExactTokenCount || budget <= 0 || !ok {
+		return
+	}
+
+	for range maxBudgetFitRounds {
+		// Count the history alone; the budget does not cover the system prompt or tools.
+		count, err := counter.CountTokens(ctx, CompletionRequest{Model: request.Model, Messages: request.Messages})
+		if err != nil {
+			ErrorLogger.Printf("Error counting tokens, using estimates: %v", err)
+			return
+		}
+		overflow := count - budget
+		if overflow <= 0 || len(request.Messages) <= 1 {
+			return
+		}
+
+		drop := 0
+		for drop < len(request.Messages)-1 && overflow > 0 {
+			overflow -= estimateContentTokens(request.Messages[drop])
+			drop++
+		}
+		for drop < len(request.Messages)-1 && request.Messages[drop].Role != anthropic.RoleUser {
+			drop++
+		}
+		InfoLogger.Printf("[%s] History is %d tokens over budget; dropping %d oldest messages", b.config.ID, count-budget, drop)
+		request.Messages = request.Messages[drop:]
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func budgetMemory(texts ...string) *ChatMemory {
+	memory := &ChatMemory{}
+	for i, text := range texts {
+		msg := Message{Text: text, IsUser: i%2 == 0}
+		msg.ID = uint(i + 1)
+		memory.Messages = append(memory.Messages, msg)
+	}
+	return memory
+}
+
+// TestContextWindow verifies that the window keeps the newest messages within the budget and
+// always starts at a user turn.
+func TestContextWindow(t *testing.T) {
+	b, _ := setupBotForTest(t, 123)
+	long := strings.Repeat("x", 400) // ~100 tokens
+
+	tests := []struct {
+		name    string
+		budget  int
+		memory  *ChatMemory
+		wantIDs []uint
+	}{
+		{"no budget keeps everything", 0, budgetMemory(long, long, long, long), []uint{1, 2, 3, 4}},
+		{"many short messages fit", 100, budgetMemory("hi", "hello", "how are you", "fine", "good"), []uint{1, 2, 3, 4, 5}},
+		{"long messages are dropped", 250, budgetMemory(long, long, long, long, "short"), []uint{3, 4, 5}},
+		{"starts at a user turn", 20, budgetMemory(long, "x", "y", "z"), []uint{3, 4}},
+		{"newest is always kept", 10, budgetMemory(long, long, long), []uint{3}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b.config.ContextTokenBudget = tc.budget
+			var ids []uint
+			for _, msg := range b.contextWindow(tc.memory) {
+				ids = append(ids, msg.ID)
+			}
+			assert.Equal(t, tc.wantIDs, ids)
+		})
+	}
+}
+
+// TestFitRequestToBudget verifies that exact counting drops the oldest turns until the history fits
+// and that counting failures leave the request unchanged.
+func TestFitRequestToBudget(t *testing.T) {
+	b, _ := setupBotForTest(t, 123)
+	b.config.ContextTokenBudget = 100
+	b.config.ExactTokenCount = true
+
+	messages := func() []anthropic.Message {
+		return []anthropic.Message{
+			anthropic.NewUserTextMessage(strings.Repeat("a", 200)),
+			anthropic.NewAssistantTextMessage(strings.Repeat("b", 200)),
+			anthropic.NewUserTextMessage("latest"),
+		}
+	}
+
+	var calls int
+	b.llm = &MockLLMProvider{CountTokensFunc: func(_ context.Context, req CompletionRequest) (int, error) {
+		calls++
+		assert.Empty(t, req.System)
+		if len(req.Messages) > 1 {
+			return 150, nil
+		}
+		return 10, nil
+	}}
+	request := CompletionRequest{Model: "claude-v1", Messages: messages()}
+	b.fitRequestToBudget(context.Background(), &request)
+	if assert.Len(t, request.Messages, 1) {
+		assert.Equal(t, "latest", *request.Messages[0].Content[0].Text)
+	}
+	assert.Equal(t, 2, calls)
+
+	b.llm = &MockLLMProvider{CountTokensFunc: func(context.Context, CompletionRequest) (int, error) {
+		return 0, errors.New("unavailable")
+	}}
+	request = CompletionRequest{Model: "claude-v1", Messages: messages()}
+	b.fitRequestToBudget(context.Background(), &request)
+	assert.Len(t, request.Messages, 3)
+}
+
+// TestMaxOutputTokens verifies that the configured reply limit reaches the completion request.
+func TestMaxOutputTokens(t *testing.T) {
+	b, _ := setupBotForTest(t, 123)
+	request := b.buildCompletionRequest(context.Background(), 789, 789, nil, false, false, false, "", "", "", false, "", 0)
+	assert.Equal(t, defaultMaxOutputTokens, request.MaxTokens)
+
+	b.config.MaxOutputTokens = 300
+	request = b.buildCompletionRequest(context.Background(), 789, 789, nil, false, false, false, "", "", "", false, "", 0)
+	assert.Equal(t, 300, request.MaxTokens)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"slices"
 	"time"
 
 	"gorm.io/driver/sqlite"
@@ -39,11 +40,18 @@ func initDB() (*gorm.DB, error) {
 	sqlDB.SetMaxOpenConns(1)
 
 	// AutoMigrate the models
-	err = db.AutoMigrate(&BotModel{}, &ConfigModel{}, &Message{}, &User{}, &Role{}, &Scope{})
+	err = db.AutoMigrate(&BotModel{}, &ConfigModel{}, &Message{}, &User{}, &Role{}, &Scope{}, &GroupSettings{}, &ChatSummary{}, &RateLimitState{}, &Ban{}, &AuditEntry{}, &AccessEntry{}, &InviteCode{}, &ChatPersona{}, &UserInstructions{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database schema: %w", err)
 	}
 
+	// Role names used to be unique globally; they are unique per bot now (idx_role_bot_name).
+	if db.Migrator().HasIndex(&Role{}, "idx_roles_name") {
+		if err := db.Migrator().DropIndex(&Role{}, "idx_roles_name"); err != nil {
+			return nil, fmt.Errorf("failed to drop the global role name index: %w", err)
+		}
+	}
+
 	// Enforce unique owner per bot using raw SQL
 	// Note: SQLite doesn't support partial indexes, but we can simulate it by making a unique index on (BotID, IsOwner)
 	// and ensuring that IsOwner can only be true for one user per BotID.
@@ -66,57 +74,95 @@ func initDB() (*gorm.DB, error) {
 	return db, nil
 }
 
+// createDefaultScopes seeds the known scopes and grants the default ones to the default roles.
+// Only scopes created by this call are granted, so grants and revocations made from Telegram
+// survive restarts while scopes added in a new release still reach the existing roles.
 func createDefaultScopes(db *gorm.DB) error {
 	all := []string{
 		ScopeStatsViewOwn, ScopeStatsViewAny,
 		ScopeHistoryClearOwn, ScopeHistoryClearAny,
 		ScopeHistoryClearHardOwn, ScopeHistoryClearHardAny,
 		ScopeModelSet, ScopeUserPromote, ScopeTTSUse,
+		ScopeToolTime, ScopeToolUserStats, ScopeToolCalculator,
+		ScopeVisionUse, ScopeRateLimitBypass,
+		ScopeLimitsViewOwn, ScopeLimitsViewAny, ScopeUserBan,
+		ScopeUserDemote, ScopeUserList, ScopeRoleView, ScopeRoleManage,
+		ScopeUserAccess, ScopeInstructionsManage,
 	}
+	created := make(map[string]bool, len(all))
 	for _, name := range all {
-		if err := db.FirstOrCreate(&Scope{}, Scope{Name: name}).Error; err != nil {
-			return fmt.Errorf("failed to create scope %s: %w", name, err)
+		result := db.FirstOrCreate(&Scope{}, Scope{Name: name})
+		if result.Error != nil {
+			return fmt.Errorf("failed to create scope %s: %w", name, result.Error)
 		}
+		created[name] = result.RowsAffected > 0
 	}
 
 	userScopes := []string{
 		ScopeStatsViewOwn,
 		ScopeHistoryClearOwn,
 		ScopeHistoryClearHardOwn,
+		ScopeToolTime, ScopeToolUserStats, ScopeToolCalculator,
+		ScopeLimitsViewOwn,
 	}
 	elevatedScopes := []string{
 		ScopeStatsViewOwn, ScopeStatsViewAny,
 		ScopeHistoryClearOwn, ScopeHistoryClearAny,
 		ScopeHistoryClearHardOwn, ScopeHistoryClearHardAny,
 		ScopeModelSet, ScopeUserPromote, ScopeTTSUse,
+		ScopeToolTime, ScopeToolUserStats, ScopeToolCalculator,
+		ScopeVisionUse,
+		ScopeLimitsViewOwn, ScopeLimitsViewAny, ScopeUserBan,
+		ScopeUserDemote, ScopeUserList, ScopeRoleView,
+		ScopeUserAccess, ScopeInstructionsManage,
 	}
 	assignments := map[string][]string{
 		"user":  userScopes,
 		"admin": elevatedScopes,
-		// owner gets the same scopes as admin; owner uniqueness is enforced by the IsOwner flag
-		"owner": elevatedScopes,
+		// owner gets the admin scopes and is not rate limited; owner uniqueness is enforced by the IsOwner flag
+		"owner": append(slices.Clone(elevatedScopes), ScopeRateLimitBypass, ScopeRoleManage),
 	}
 	for roleName, scopes := range assignments {
-		var role Role
-		if err := db.Where("name = ?", roleName).First(&role).Error; err != nil {
-			return fmt.Errorf("role %s not found: %w", roleName, err)
+		scopes = slices.DeleteFunc(slices.Clone(scopes), func(name string) bool { return !created[name] })
+		if len(scopes) == 0 {
+			continue
 		}
-		var scopeModels []Scope
-		if err := db.Where("name IN ?", scopes).Find(&scopeModels).Error; err != nil {
-			return fmt.Errorf("failed to find scopes for %s: %w", roleName, err)
+		if err := grantScopesToRoles(db, roleName, scopes); err != nil {
+			return err
 		}
-		if err := db.Model(&role).Association("Scopes").Replace(scopeModels); err != nil {
+	}
+	return nil
+}
+
+// grantScopesToRoles grants the named scopes to every role named roleName: the global role and
+// the bots' own copies of it.
+func grantScopesToRoles(db *gorm.DB, roleName string, scopes []string) error {
+	var scopeModels []Scope
+	if err := db.Where("name IN ?", scopes).Find(&scopeModels).Error; err != nil {
+		return fmt.Errorf("failed to find scopes for %s: %w", roleName, err)
+	}
+	var roles []Role
+	if err := db.Where("name = ?", roleName).Find(&roles).Error; err != nil {
+		return fmt.Errorf("failed to find roles named %s: %w", roleName, err)
+	}
+	if len(roles) == 0 {
+		return fmt.Errorf("role %s not found", roleName)
+	}
+	for _, role := range roles {
+		if err := db.Model(&role).Association("Scopes").Append(scopeModels); err != nil {
 			return fmt.Errorf("failed to assign scopes to %s: %w", roleName, err)
 		}
 	}
 	return nil
 }
 
+// defaultRoles are seeded globally on startup and can't be deleted.
+var defaultRoles = []string{defaultRoleName, "admin", "owner"}
+
 func createDefaultRoles(db *gorm.DB) error {
-	roles := []string{"user", "admin", "owner"}
-	for _, roleName := range roles {
+	for _, roleName := range defaultRoles {
 		var role Role
-		if err := db.FirstOrCreate(&role, Role{Name: roleName}).Error; err != nil {
+		if err := db.Where("bot_id = 0").FirstOrCreate(&role, Role{Name: roleName}).Error; err != nil {
 			ErrorLogger.Printf("Failed to create default role %s: %v", roleName, err)
 			return fmt.Errorf("failed to create default role %s: %w", roleName, err)
 		}
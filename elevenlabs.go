@@ -18,8 +18,9 @@ const (
 	elevenLabsDefaultModel = "eleven_multilingual_v2"
 )
 
-// generateSpeech converts text to an mp3 audio stream via ElevenLabs TTS.
-func (b *Bot) generateSpeech(ctx context.Context, text string) (io.Reader, error) {
+// generateSpeech converts text to an mp3 audio stream via ElevenLabs TTS, in the voice of the
+// chat's persona.
+func (b *Bot) generateSpeech(ctx context.Context, chatID int64, text string) (io.Reader, error) {
 	model := b.config.ElevenLabsModel
 	if model == "" {
 		model = elevenLabsDefaultModel
@@ -32,7 +33,7 @@ func (b *Bot) generateSpeech(ctx context.Context, text string) (io.Reader, error
 		return nil, fmt.Errorf("elevenlabs TTS marshal error: %w", err)
 	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
-		elevenLabsTTSURL+b.config.ElevenLabsVoiceID, bytes.NewReader(body))
+		elevenLabsTTSURL+b.voiceID(chatID), bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("elevenlabs TTS request error: %w", err)
 	}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"gorm.io/gorm"
+)
+
+// Group trigger modes: when the bot replies to a message in a group.
+const (
+	GroupTriggerMention = "mention" // @mention or reply to one of the bot's messages
+	GroupTriggerKeyword = "keyword" // as "mention", or a message containing one of group_keywords
+	GroupTriggerAll     = "all"     // every message
+)
+
+const groupUsage = "Usage:\n" +
+	"/group — show settings\n" +
+	"/group on|off — enable or disable replies in this group\n" +
+	"/group trigger mention|keyword|all — choose when the bot replies\n" +
+	"/group topics all|<id>[,<id>...] — limit replies to forum topics (0 = General)"
+
+// isGroupChat reports whether the chat is a group or supergroup.
+func isGroupChat(chat models.Chat) bool {
+	return chat.Type == models.ChatTypeGroup || chat.Type == models.ChatTypeSupergroup
+}
+
+// isGroupChatID reports whether a stored chat ID belongs to a group. Telegram uses negative IDs
+// for groups, supergroups and channels and positive IDs for private chats.
+func isGroupChatID(chatID int64) bool {
+	return chatID < 0
+}
+
+func defaultGroupSettings(botID uint, chatID int64) GroupSettings {
+	return GroupSettings{BotID: botID, ChatID: chatID, Enabled: true, TriggerMode: GroupTriggerMention}
+}
+
+// getGroupSettings returns the stored settings of a group, or the defaults when none are stored.
+func (b *Bot) getGroupSettings(chatID int64) (GroupSettings, error) {
+	var settings GroupSettings
+	err := b.db.Where("bot_id = ? AND chat_id = ?", b.botID, chatID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaultGroupSettings(b.botID, chatID), nil
+	}
+	return settings, err
+}
+
+// allowedTopics parses the stored topic list; an empty result allows every topic.
+func (s GroupSettings) allowedTopics() []int {
+	var topics []int
+	for _, part := range strings.Split(s.AllowedTopics, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			topics = append(topics, id)
+		}
+	}
+	return topics
+}
+
+// botUser returns the bot's own Telegram user, fetched once via GetMe.
+func (b *Bot) botUser(ctx context.Context) (*models.User, error) {
+	b.meMu.Lock()
+	defer b.meMu.Unlock()
+	if b.me != nil {
+		return b.me, nil
+	}
+	me, err := b.tgBot.GetMe(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("telegram GetMe error: %w", err)
+	}
+	b.me = me
+	return me, nil
+}
+
+// shouldRespondInGroup decides whether a group message addresses the bot, according to the
+// group's settings. Messages that do not trigger a reply are still kept as context.
+func (b *Bot) shouldRespondInGroup(ctx context.Context, message *models.Message) bool {
+	settings, err := b.getGroupSettings(message.Chat.ID)
+	if err != nil {
+		ErrorLogger.Printf("Error loading group settings for chat %d: %v", message.Chat.ID, err)
+		return false
+	}
+	if !settings.Enabled {
+		return false
+	}
+
+	if topics := settings.allowedTopics(); len(topics) > 0 {
+		topic := 0 // General and non-forum messages
+		if message.IsTopicMessage {
+			topic = message.MessageThreadID
+		}
+		if !slices.Contains(topics, topic) {
+			return false
+		}
+	}
+
+	if settings.TriggerMode == GroupTriggerAll {
+		return true
+	}
+
+	me, err := b.botUser(ctx)
+	if err != nil {
+		ErrorLogger.Printf("Error resolving bot identity: %v", err)
+		return false
+	}
+	if message.ReplyToMessage != nil && message.ReplyToMessage.From != nil && message.ReplyToMessage.From.ID == me.ID {
+		return true
+	}
+
+	text, entities := message.Text, message.Entities
+	if text == "" {
+		text, entities = message.Caption, message.CaptionEntities
+	}
+	if mentionsUser(text, entities, me) {
+		return true
+	}
+	if settings.TriggerMode == GroupTriggerKeyword {
+		return containsKeyword(text, b.config.GroupKeywords)
+	}
+	return false
+}
+
+// mentionsUser reports whether the entities contain an @mention of user.
+func mentionsUser(text string, entities []models.MessageEntity, user *models.User) bool {
+	for _, entity := range entities {
+		switch entity.Type {
+		case models.MessageEntityTypeMention:
+			if user.Username != "" && strings.EqualFold(entityText(text, entity), "@"+user.Username) {
+				return true
+			}
+		case models.MessageEntityTypeTextMention:
+			if entity.User != nil && entity.User.ID == user.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// entityText returns the text covered by an entity. Telegram measures offsets in UTF-16 code units.
+func entityText(text string, entity models.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	if entity.Offset < 0 || entity.Length < 0 || entity.Offset+entity.Length > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[entity.Offset : entity.Offset+entity.Length]))
+}
+
+// textAfterEntity returns the text following an entity, measured like entityText.
+func textAfterEntity(text string, entity models.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	end := entity.Offset + entity.Length
+	if entity.Offset < 0 || entity.Length < 0 || end > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[end:]))
+}
+
+// containsKeyword reports whether text contains any keyword as a whole word, ignoring case.
+func containsKeyword(text string, keywords []string) bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-'
+	})
+	for _, keyword := range keywords {
+		if slices.Contains(words, strings.ToLower(strings.TrimSpace(keyword))) {
+			return true
+		}
+	}
+	return false
+}
+
+// isGroupAdmin reports whether the user is the creator or an administrator of the group.
+func (b *Bot) isGroupAdmin(ctx context.Context, chatID, userID int64) bool {
+	member, err := b.tgBot.GetChatMember(ctx, &bot.GetChatMemberParams{ChatID: chatID, UserID: userID})
+	if err != nil {
+		ErrorLogger.Printf("Error fetching chat member %d in chat %d: %v", userID, chatID, err)
+		return false
+	}
+	return member.Type == models.ChatMemberTypeOwner || member.Type == models.ChatMemberTypeAdministrator
+}
+
+// handleGroupCommand shows or changes the settings of the current group. Only group admins may use it.
+func (b *Bot) handleGroupCommand(ctx context.Context, c *commandCall) {
+	chatID, userID := c.chatID(), c.userID()
+	if !isGroupChat(c.message.Chat) {
+		c.reply("This command only works in groups.")
+		return
+	}
+	if !b.isGroupAdmin(ctx, chatID, userID) {
+		c.reply("Permission denied. Only group admins can change group settings.")
+		return
+	}
+
+	settings, err := b.getGroupSettings(chatID)
+	if err != nil {
+		ErrorLogger.Printf("Error loading group settings for chat %d: %v", chatID, err)
+		c.reply("Sorry, I couldn't load the group settings.")
+		return
+	}
+
+	if !c.has("setting") {
+		c.reply(formatGroupSettings(settings))
+		return
+	}
+
+	setting, value := c.str("setting"), c.str("value")
+	switch {
+	case (setting == "on" || setting == "off") && !c.has("value"):
+		settings.Enabled = setting == "on"
+	case setting == "trigger" && c.has("value"):
+		switch value {
+		case GroupTriggerMention, GroupTriggerKeyword, GroupTriggerAll:
+			settings.TriggerMode = value
+		default:
+			c.reply(groupUsage)
+			return
+		}
+	case setting == "topics" && c.has("value"):
+		if value == "all" {
+			settings.AllowedTopics = ""
+			break
+		}
+		var ids []string
+		for _, part := range strings.Split(value, ",") {
+			if _, err := strconv.Atoi(part); err != nil {
+				c.reply(groupUsage)
+				return
+			}
+			ids = append(ids, part)
+		}
+		settings.AllowedTopics = strings.Join(ids, ",")
+	default:
+		c.reply(groupUsage)
+		return
+	}
+
+	if err := b.db.Save(&settings).Error; err != nil {
+		ErrorLogger.Printf("Error saving group settings for chat %d: %v", chatID, err)
+		c.reply("Sorry, I couldn't save the group settings.")
+		return
+	}
+	InfoLogger.Printf("[%s] Group settings for chat %d changed by user %d: %s", b.config.ID, chatID, userID, strings.TrimSpace(setting+" "+value))
+	c.reply("✅ Group settings updated.\n\n" + formatGroupSettings(settings))
+}
+
+func formatGroupSettings(s GroupSettings) string {
+	status := "enabled"
+	if !s.Enabled {
+		status = "disabled"
+	}
+	topics := s.AllowedTopics
+	if topics == "" {
+		topics = "all"
+	}
+	return fmt.Sprintf("Group settings:\nReplies: %s\nTrigger: %s\nTopics: %s", status, s.TriggerMode, topics)
+}
+
+// speakerLabel names the author of a stored user message, so the model can tell group
+// participants apart.
+func speakerLabel(msg Message) string {
+	name := strings.TrimSpace(msg.DisplayName)
+	switch {
+	case name != "" && msg.Username != "":
+		return fmt.Sprintf("%s (@%s)", name, msg.Username)
+	case name != "":
+		return name
+	case msg.Username != "":
+		return "@" + msg.Username
+	}
+	return fmt.Sprintf("User %d", msg.UserID)
+}
+
+type replyToKey struct{}
+
+// withReplyTo marks ctx so replies sent with it quote the given message. In groups this keeps
+// answers attached to the question (and inside the right forum topic).
+func withReplyTo(ctx context.Context, messageID int) context.Context {
+	return context.WithValue(ctx, replyToKey{}, messageID)
+}
+
+// replyParameters returns the reply parameters stored by withReplyTo, or nil.
+func replyParameters(ctx context.Context) *models.ReplyParameters {
+	messageID, ok := ctx.Value(replyToKey{}).(int)
+	if !ok || messageID == 0 {
+		return nil
+	}
+	return &models.ReplyParameters{MessageID: messageID, AllowSendingWithoutReply: true}
+}
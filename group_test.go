@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+const testGroupID int64 = -100123
+
+func groupMessage(text string) *models.Message {
+	return &models.Message{
+		ID:   42,
+		Chat: models.Chat{ID: testGroupID, Type: models.ChatTypeSupergroup},
+		From: &models.User{ID: 789, Username: "alice", FirstName: "Alice"},
+		Text: text,
+	}
+}
+
+// TestShouldRespondInGroup verifies mention, reply, keyword and topic triggering.
+func TestShouldRespondInGroup(t *testing.T) {
+	b, _ := setupBotForTest(t, 123)
+	b.config.GroupKeywords = []string{"Atom"}
+
+	mention := groupMessage("👋 hey @Test_Bot what's up")
+	mention.Entities = []models.MessageEntity{{Type: models.MessageEntityTypeMention, Offset: 7, Length: 9}}
+
+	otherMention := groupMessage("@someone_else hi")
+	otherMention.Entities = []models.MessageEntity{{Type: models.MessageEntityTypeMention, Offset: 0, Length: 13}}
+
+	textMention := groupMessage("Bot, hi")
+	textMention.Entities = []models.MessageEntity{{Type: models.MessageEntityTypeTextMention, Offset: 0, Length: 3, User: &models.User{ID: 999}}}
+
+	reply := groupMessage("thanks")
+	reply.ReplyToMessage = &models.Message{From: &models.User{ID: 999}}
+
+	topicMessage := groupMessage("anyone?")
+	topicMessage.IsTopicMessage = true
+	topicMessage.MessageThreadID = 7
+
+	tests := []struct {
+		name     string
+		settings *GroupSettings
+		message  *models.Message
+		want     bool
+	}{
+		{"plain message", nil, groupMessage("hello everyone"), false},
+		{"mention after emoji", nil, mention, true},
+		{"mention of someone else", nil, otherMention, false},
+		{"text mention", nil, textMention, true},
+		{"reply to bot", nil, reply, true},
+		{"keyword ignored in mention mode", nil, groupMessage("atom, are you there?"), false},
+		{"keyword", &GroupSettings{Enabled: true, TriggerMode: GroupTriggerKeyword}, groupMessage("atom, are you there?"), true},
+		{"keyword must be a whole word", &GroupSettings{Enabled: true, TriggerMode: GroupTriggerKeyword}, groupMessage("atomic habits"), false},
+		{"all", &GroupSettings{Enabled: true, TriggerMode: GroupTriggerAll}, groupMessage("hello everyone"), true},
+		{"disabled", &GroupSettings{Enabled: false, TriggerMode: GroupTriggerAll}, groupMessage("hello everyone"), false},
+		{"allowed topic", &GroupSettings{Enabled: true, TriggerMode: GroupTriggerAll, AllowedTopics: "3,7"}, topicMessage, true},
+		{"other topic", &GroupSettings{Enabled: true, TriggerMode: GroupTriggerAll, AllowedTopics: "3"}, topicMessage, false},
+		{"general topic", &GroupSettings{Enabled: true, TriggerMode: GroupTriggerAll, AllowedTopics: "0"}, groupMessage("hi"), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b.db.Unscoped().Where("1 = 1").Delete(&GroupSettings{})
+			if tc.settings != nil {
+				tc.settings.BotID = b.botID
+				tc.settings.ChatID = testGroupID
+				assert.NoError(t, b.db.Create(tc.settings).Error)
+			}
+			assert.Equal(t, tc.want, b.shouldRespondInGroup(context.Background(), tc.message))
+		})
+	}
+}
+
+// TestHandleUpdate_Group verifies that unaddressed group messages are kept as context without a
+// reply, and that addressed ones are answered as a reply with speaker names in the context.
+func TestHandleUpdate_Group(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, mockTgClient := setupBotForTest(t, 123)
+
+	var requests []CompletionRequest
+	b.llm = &MockLLMProvider{CompleteFunc: func(_ context.Context, req CompletionRequest) (CompletionResponse, error) {
+		requests = append(requests, req)
+		return CompletionResponse{Text: "Hi both!"}, nil
+	}}
+	var sent []*bot.SendMessageParams
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		sent = append(sent, params)
+		return &models.Message{}, nil
+	}
+
+	first := groupMessage("morning all")
+	first.From = &models.User{ID: 555, Username: "bob", FirstName: "Bob", LastName: "Stone"}
+	b.handleUpdate(context.Background(), nil, &models.Update{Message: first})
+	assert.Empty(t, requests, "unaddressed message must not reach the model")
+	assert.Empty(t, sent)
+
+	second := groupMessage("@test_bot say hi")
+	second.Entities = []models.MessageEntity{{Type: models.MessageEntityTypeMention, Offset: 0, Length: 9}}
+	b.handleUpdate(context.Background(), nil, &models.Update{Message: second})
+
+	if assert.Len(t, requests, 1) && assert.Len(t, requests[0].Messages, 2) {
+		assert.Equal(t, "Bob Stone (@bob): morning all", *requests[0].Messages[0].Content[0].Text)
+		assert.Equal(t, "Alice (@alice): @test_bot say hi", *requests[0].Messages[1].Content[0].Text)
+	}
+	if assert.Len(t, sent, 1) && assert.NotNil(t, sent[0].ReplyParameters) {
+		assert.Equal(t, 42, sent[0].ReplyParameters.MessageID)
+	}
+}
+
+// TestHandleGroupCommand verifies that only group admins can change settings and that changes persist.
+func TestHandleGroupCommand(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+
+	var lastSent string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+	admins := map[int64]bool{1000: true}
+	mockTgClient.GetChatMemberFunc = func(ctx context.Context, params *bot.GetChatMemberParams) (*models.ChatMember, error) {
+		if admins[params.UserID] {
+			return &models.ChatMember{Type: models.ChatMemberTypeAdministrator}, nil
+		}
+		return &models.ChatMember{Type: models.ChatMemberTypeMember}, nil
+	}
+
+	command := func(userID, chatID int64, text string) {
+		assert.True(t, b.dispatchCommand(context.Background(), commandUpdate(userID, chatID, text).Message, ""))
+	}
+
+	command(789, testGroupID, "/group off")
+	assert.Contains(t, lastSent, "Permission denied")
+	settings, err := b.getGroupSettings(testGroupID)
+	assert.NoError(t, err)
+	assert.True(t, settings.Enabled)
+
+	command(1000, testGroupID, "/group trigger keyword")
+	command(1000, testGroupID, "/group topics 3,5")
+	command(1000, testGroupID, "/group off")
+	assert.Contains(t, lastSent, "Group settings updated")
+	settings, err = b.getGroupSettings(testGroupID)
+	assert.NoError(t, err)
+	assert.False(t, settings.Enabled)
+	assert.Equal(t, GroupTriggerKeyword, settings.TriggerMode)
+	assert.Equal(t, []int{3, 5}, settings.allowedTopics())
+
+	command(1000, testGroupID, "/group trigger sometimes")
+	assert.Contains(t, lastSent, "Usage:")
+	command(1000, testGroupID, "/group trigger all now")
+	assert.Equal(t, "Usage: /group, /group on|off, /group trigger mention|keyword|all or /group topics all|<id>[,<id>...], e.g. /group trigger keyword", lastSent)
+	command(1000, testGroupID, "/group")
+	assert.Equal(t, formatGroupSettings(settings), lastSent)
+
+	command(1000, 1000, "/group")
+	assert.Equal(t, "This command only works in groups.", lastSent)
+}
+
+// TestClearChatHistory_GroupRequiresAdmin verifies that clearing the shared group history is
+// restricted to group admins.
+func TestClearChatHistory_GroupRequiresAdmin(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, mockTgClient := setupBotForTest(t, 123)
+	var lastSent string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+
+	msg := b.createMessage(testGroupID, 555, "bob", "user", "keep me", true)
+	assert.NoError(t, b.storeMessage(&msg))
+
+	b.clearChatHistory(context.Background(), testGroupID, 789, 0, 0, "", false)
+	assert.Contains(t, lastSent, "Only group admins")
+
+	var count int64
+	b.db.Model(&Message{}).Where("chat_id = ? AND text = ?", testGroupID, "keep me").Count(&count)
+	assert.Equal(t, int64(1), count)
+}
@@ -4,8 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
-	"strings"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -53,9 +51,8 @@ func (b *Bot) handleVoiceMessage(ctx context.Context, message *models.Message, u
 	}
 	b.chatMemoriesMu.Unlock()
 
-	chatMemory := b.getOrCreateChatMemory(chatID)
-	contextMessages := b.prepareContextMessages(chatMemory)
-	response, err := b.getAnthropicResponse(ctx, contextMessages, isNewChat, isOwner, false, username, firstName, lastName, isPremium, languageCode, messageTime)
+	contextMessages := b.buildContext(ctx, chatID)
+	response, err := b.getAnthropicResponse(ctx, chatID, userID, contextMessages, isNewChat, isOwner, false, username, firstName, lastName, isPremium, languageCode, messageTime)
 	if err != nil {
 		ErrorLogger.Printf("Error getting Anthropic response for voice: %v", err)
 		if err := b.sendResponse(ctx, chatID, b.anthropicErrorResponse(err, userID), businessConnectionID); err != nil {
@@ -64,7 +61,7 @@ func (b *Bot) handleVoiceMessage(ctx context.Context, message *models.Message, u
 		return
 	}
 
-	audioReader, err := b.generateSpeech(ctx, response)
+	audioReader, err := b.generateSpeech(ctx, chatID, response)
 	if err != nil {
 		// TTS failed — fall back to text so the user still gets a reply.
 		ErrorLogger.Printf("Error generating speech, falling back to text: %v", err)
@@ -75,13 +72,14 @@ func (b *Bot) handleVoiceMessage(ctx context.Context, message *models.Message, u
 	}
 
 	// Store the assistant response before sending.
-	if _, err := b.screenOutgoingMessage(chatID, response); err != nil {
+	if _, err := b.screenOutgoingMessage(ctx, chatID, response); err != nil {
 		ErrorLogger.Printf("Error storing assistant voice response: %v", err)
 	}
 
 	params := &bot.SendAudioParams{
-		ChatID: chatID,
-		Audio:  &models.InputFileUpload{Filename: "response.mp3", Data: audioReader},
+		ChatID:          chatID,
+		Audio:           &models.InputFileUpload{Filename: "response.mp3", Data: audioReader},
+		ReplyParameters: replyParameters(ctx),
 	}
 	if businessConnectionID != "" {
 		params.BusinessConnectionID = businessConnectionID
@@ -96,11 +94,15 @@ func (b *Bot) handleVoiceMessage(ctx context.Context, message *models.Message, u
 // always get the generic fallback to avoid leaking internal details.
 func (b *Bot) anthropicErrorResponse(err error, userID int64) string {
 	if errors.Is(err, ErrModelNotFound) && b.hasScope(userID, ScopeModelSet) {
-		return fmt.Sprintf(
-			"⚠️ Model `%s` is no longer available (deprecated or removed by Anthropic).\n"+
-				"Use /set_model <model-id> to switch. Current models: https://platform.claude.com/docs/en/about-claude/models/overview",
+		hint := fmt.Sprintf(
+			"⚠️ Model `%s` is no longer available (deprecated or removed by the provider).\n"+
+				"Use /set_model <model-id> to switch.",
 			b.config.Model,
 		)
+		if b.config.Provider == "" || b.config.Provider == ProviderAnthropic {
+			hint += " Current models: https://platform.claude.com/docs/en/about-claude/models/overview"
+		}
+		return hint
 	}
 	return "I'm sorry, I'm having trouble processing your request right now."
 }
@@ -131,6 +133,17 @@ func (b *Bot) handleUpdate(ctx context.Context, tgBot *bot.Bot, update *models.U
 		return
 	}
 
+	// Banned users are ignored before anything is stored or answered.
+	if _, banned := b.activeBan(message.From.ID); banned {
+		InfoLogger.Printf("[%s] Ignoring message from banned user %d", b.config.ID, message.From.ID)
+		return
+	}
+
+	// So are denylisted users, and users without access in allowlist and invite-only modes.
+	if !b.checkAccess(ctx, message) {
+		return
+	}
+
 	chatID := message.Chat.ID
 	userID := message.From.ID
 	username := message.From.Username
@@ -151,6 +164,9 @@ func (b *Bot) handleUpdate(ctx context.Context, tgBot *bot.Bot, update *models.U
 		return
 	}
 
+	// Model usage spent on this update is billed to the sender and stored on the reply.
+	ctx = withUsageRecorder(ctx, userID)
+
 	// Determine if the user is the owner
 	var isOwner bool
 	if b.db.Where("telegram_id = ? AND bot_id = ? AND is_owner = ?", userID, b.botID, true).First(&User{}).Error == nil {
@@ -173,144 +189,29 @@ func (b *Bot) handleUpdate(ctx context.Context, tgBot *bot.Bot, update *models.U
 	}
 
 	// Check if the message is a command — applies on every message, including the very first.
-	if message.Entities != nil {
-		for _, entity := range message.Entities {
-			if entity.Type == "bot_command" {
-				command := strings.TrimSpace(message.Text[entity.Offset : entity.Offset+entity.Length])
-				switch command {
-				case "/stats":
-					// Parse command parameters
-					parts := strings.Fields(message.Text)
-
-					// Default: show global stats
-					if len(parts) == 1 {
-						b.sendStats(ctx, chatID, userID, 0, businessConnectionID)
-						return
-					}
-
-					// Check for "user" parameter
-					if len(parts) >= 2 && parts[1] == "user" {
-						targetUserID := userID // Default to current user
-
-						// If a user ID is provided, parse it
-						if len(parts) >= 3 {
-							var parseErr error
-							targetUserID, parseErr = strconv.ParseInt(parts[2], 10, 64)
-							if parseErr != nil {
-								InfoLogger.Printf("User %d provided invalid user ID format: %s", userID, parts[2])
-								if err := b.sendResponse(ctx, chatID, "Invalid user ID format. Usage: /stats user [user_id]", businessConnectionID); err != nil {
-									ErrorLogger.Printf("Error sending response: %v", err)
-								}
-								return
-							}
-						}
-
-						b.sendStats(ctx, chatID, userID, targetUserID, businessConnectionID)
-						return
-					}
-
-					// Invalid parameter
-					if err := b.sendResponse(ctx, chatID, "Invalid command format. Usage: /stats or /stats user [user_id]", businessConnectionID); err != nil {
-						ErrorLogger.Printf("Error sending response: %v", err)
-					}
-					return
-				case "/whoami":
-					b.sendWhoAmI(ctx, chatID, userID, username, businessConnectionID)
-					return
-				case "/clear":
-					parts := strings.Fields(message.Text)
-					var targetUserID, targetChatID int64
-					if len(parts) > 1 {
-						var parseErr error
-						targetUserID, parseErr = strconv.ParseInt(parts[1], 10, 64)
-						if parseErr != nil {
-							InfoLogger.Printf("User %d provided invalid user ID format: %s", userID, parts[1])
-							if err := b.sendResponse(ctx, chatID, "Invalid user ID format. Usage: /clear [user_id] [chat_id]", businessConnectionID); err != nil {
-								ErrorLogger.Printf("Error sending response: %v", err)
-							}
-							return
-						}
-					}
-					if len(parts) > 2 {
-						var parseErr error
-						targetChatID, parseErr = strconv.ParseInt(parts[2], 10, 64)
-						if parseErr != nil {
-							InfoLogger.Printf("User %d provided invalid chat ID format: %s", userID, parts[2])
-							if err := b.sendResponse(ctx, chatID, "Invalid chat ID format. Usage: /clear [user_id] [chat_id]", businessConnectionID); err != nil {
-								ErrorLogger.Printf("Error sending response: %v", err)
-							}
-							return
-						}
-					}
-					b.clearChatHistory(ctx, chatID, userID, targetUserID, targetChatID, businessConnectionID, false)
-					return
-				case "/set_model":
-					if !b.hasScope(userID, ScopeModelSet) {
-						if err := b.sendResponse(ctx, chatID, "Permission denied. Only admins and owners can change the model.", businessConnectionID); err != nil {
-							ErrorLogger.Printf("Error sending response: %v", err)
-						}
-						return
-					}
-					parts := strings.Fields(message.Text)
-					if len(parts) < 2 || strings.TrimSpace(parts[1]) == "" {
-						if err := b.sendResponse(ctx, chatID, "Usage: /set_model <model-id>", businessConnectionID); err != nil {
-							ErrorLogger.Printf("Error sending response: %v", err)
-						}
-						return
-					}
-					newModel := strings.TrimSpace(parts[1])
-					// No upfront model validation:
-					// - The go-anthropic library constants are not enumerable at runtime (Go has no const reflection).
-					// - A live /v1/models probe would add a network round-trip and show in the API audit log.
-					// - An invalid model ID will produce a 404 on the next real message, which routes through
-					//   anthropicErrorResponse and already delivers an actionable admin-facing hint.
-					if err := b.config.PersistModel(newModel); err != nil {
-						ErrorLogger.Printf("Failed to persist model change: %v", err)
-						if err := b.sendResponse(ctx, chatID, fmt.Sprintf("Model updated in memory to `%s`, but failed to save to config file: %v", newModel, err), businessConnectionID); err != nil {
-							ErrorLogger.Printf("Error sending response: %v", err)
-						}
-						return
-					}
-					InfoLogger.Printf("Model changed to %s by user %d", newModel, userID)
-					if err := b.sendResponse(ctx, chatID, fmt.Sprintf("✅ Model updated to `%s` and saved to config.", newModel), businessConnectionID); err != nil {
-						ErrorLogger.Printf("Error sending response: %v", err)
-					}
-					return
-				case "/clear_hard":
-					parts := strings.Fields(message.Text)
-					var targetUserID, targetChatID int64
-					if len(parts) > 1 {
-						var parseErr error
-						targetUserID, parseErr = strconv.ParseInt(parts[1], 10, 64)
-						if parseErr != nil {
-							InfoLogger.Printf("User %d provided invalid user ID format: %s", userID, parts[1])
-							if err := b.sendResponse(ctx, chatID, "Invalid user ID format. Usage: /clear_hard [user_id] [chat_id]", businessConnectionID); err != nil {
-								ErrorLogger.Printf("Error sending response: %v", err)
-							}
-							return
-						}
-					}
-					if len(parts) > 2 {
-						var parseErr error
-						targetChatID, parseErr = strconv.ParseInt(parts[2], 10, 64)
-						if parseErr != nil {
-							InfoLogger.Printf("User %d provided invalid chat ID format: %s", userID, parts[2])
-							if err := b.sendResponse(ctx, chatID, "Invalid chat ID format. Usage: /clear_hard [user_id] [chat_id]", businessConnectionID); err != nil {
-								ErrorLogger.Printf("Error sending response: %v", err)
-							}
-							return
-						}
-					}
-					b.clearChatHistory(ctx, chatID, userID, targetUserID, targetChatID, businessConnectionID, true)
-					return
-				}
-			}
+	if b.dispatchCommand(ctx, message, businessConnectionID) {
+		return
+	}
+
+	// In groups, only reply when addressed; other messages stay in memory as context.
+	if isGroupChat(message.Chat) {
+		if !b.shouldRespondInGroup(ctx, message) {
+			return
 		}
+		ctx = withReplyTo(ctx, message.ID)
 	}
 
 	// Rate limit check applies to all message types including stickers.
-	if !b.checkRateLimits(userID) {
-		b.sendRateLimitExceededMessage(ctx, chatID, businessConnectionID)
+	if decision := b.rateLimit(userID); !decision.allowed {
+		b.sendRateLimitExceededMessage(ctx, chatID, decision, businessConnectionID)
+		return
+	}
+
+	// Spending quotas are checked before any model call is made for the message.
+	if refusal, ok := b.checkQuotas(ctx, userID); !ok {
+		if err := b.sendResponse(ctx, chatID, refusal, businessConnectionID); err != nil {
+			ErrorLogger.Printf("Error sending quota exceeded message: %v", err)
+		}
 		return
 	}
 
@@ -321,9 +222,14 @@ func (b *Bot) handleUpdate(ctx context.Context, tgBot *bot.Bot, update *models.U
 		return
 	}
 
+	// Photos and image documents are answered with the image in context (the caption is the text).
+	if image, ok := b.imageFromMessage(message); ok {
+		b.handleImageMessage(ctx, image, userMsg, chatID, userID, username, firstName, lastName, isPremium, languageCode, messageTime, isNewChatFlag, isOwner, businessConnectionID)
+		return
+	}
+
 	// Build context once — shared by the sticker and text response paths.
-	chatMemory := b.getOrCreateChatMemory(chatID)
-	contextMessages := b.prepareContextMessages(chatMemory)
+	contextMessages := b.buildContext(ctx, chatID)
 
 	// Check if the message contains a sticker
 	if message.Sticker != nil {
@@ -340,8 +246,18 @@ func (b *Bot) handleUpdate(ctx context.Context, tgBot *bot.Bot, update *models.U
 	// Determine if the text contains only emojis
 	isEmojiOnly := isOnlyEmojis(text)
 
+	// Stream the reply into a progressively edited message when enabled and supported by the provider.
+	// Tool use needs complete responses, so it takes precedence over streaming.
+	if streamer, ok := b.llm.(StreamingProvider); ok && b.config.StreamResponses && !b.config.EnableTools {
+		request := b.buildCompletionRequest(ctx, chatID, userID, contextMessages, isNewChatFlag, isOwner, isEmojiOnly, username, firstName, lastName, isPremium, languageCode, messageTime)
+		if err := b.sendStreamingResponse(ctx, chatID, userID, request, streamer, businessConnectionID); err != nil {
+			ErrorLogger.Printf("Error sending streamed response: %v", err)
+		}
+		return
+	}
+
 	// Get response from Anthropic
-	response, err := b.getAnthropicResponse(ctx, contextMessages, isNewChatFlag, isOwner, isEmojiOnly, username, firstName, lastName, isPremium, languageCode, messageTime)
+	response, err := b.getAnthropicResponse(ctx, chatID, userID, contextMessages, isNewChatFlag, isOwner, isEmojiOnly, username, firstName, lastName, isPremium, languageCode, messageTime)
 	if err != nil {
 		ErrorLogger.Printf("Error getting Anthropic response: %v", err)
 		response = b.anthropicErrorResponse(err, userID)
@@ -354,8 +270,12 @@ func (b *Bot) handleUpdate(ctx context.Context, tgBot *bot.Bot, update *models.U
 	}
 }
 
-func (b *Bot) sendRateLimitExceededMessage(ctx context.Context, chatID int64, businessConnectionID string) {
-	if err := b.sendResponse(ctx, chatID, "Rate limit exceeded. Please try again later.", businessConnectionID); err != nil {
+func (b *Bot) sendRateLimitExceededMessage(ctx context.Context, chatID int64, decision rateLimitDecision, businessConnectionID string) {
+	text := fmt.Sprintf("Rate limit exceeded. You can send your next message in %s.", formatWait(decision.retryAfter))
+	if decision.banned {
+		text = fmt.Sprintf("Rate limit exceeded repeatedly. You are blocked for %s.", formatWait(decision.retryAfter))
+	}
+	if err := b.sendResponse(ctx, chatID, text, businessConnectionID); err != nil {
 		ErrorLogger.Printf("Error sending rate limit exceeded message: %v", err)
 	}
 }
@@ -389,7 +309,11 @@ func (b *Bot) generateStickerResponse(ctx context.Context, message Message, cont
 	// "Sent a sticker: <emoji>"), so the full conversation history is preserved.
 	if message.StickerFileID != "" {
 		messageTime := int(message.Timestamp.Unix())
-		response, err := b.getAnthropicResponse(ctx, contextMessages, false, false, true, message.Username, "", "", false, "", messageTime)
+		// With the sticker image in context the model can react to what it shows; otherwise
+		// (no usable image, or the user lacks vision:use) only the emoji is known, so keep the
+		// emoji-only reply style.
+		_, hasImage := b.imageContent(message)
+		response, err := b.getAnthropicResponse(ctx, message.ChatID, message.UserID, contextMessages, false, false, !hasImage, message.Username, "", "", false, "", messageTime)
 		if err != nil {
 			return "", err
 		}
@@ -399,7 +323,64 @@ func (b *Bot) generateStickerResponse(ctx context.Context, message Message, cont
 	return "Hmm, that's interesting!", nil
 }
 
+// handleStatsCommand handles /stats (bot-wide) and /stats user [user_id].
+func (b *Bot) handleStatsCommand(ctx context.Context, c *commandCall) {
+	var targetUserID int64
+	if c.has("user") {
+		targetUserID = c.userID()
+		if c.has("user_id") {
+			targetUserID = c.int("user_id")
+		}
+	}
+	b.sendStats(ctx, c.chatID(), c.userID(), targetUserID, c.businessConnectionID)
+}
+
+// handleWhoAmICommand handles /whoami.
+func (b *Bot) handleWhoAmICommand(ctx context.Context, c *commandCall) {
+	b.sendWhoAmI(ctx, c.chatID(), c.userID(), c.message.From.Username, c.businessConnectionID)
+}
+
+// handleSummaryCommand handles /summary.
+func (b *Bot) handleSummaryCommand(ctx context.Context, c *commandCall) {
+	b.sendSummary(ctx, c.chatID(), c.businessConnectionID)
+}
+
+// handleClearCommand handles /clear [user_id] [chat_id].
+func (b *Bot) handleClearCommand(ctx context.Context, c *commandCall) {
+	b.clearChatHistory(ctx, c.chatID(), c.userID(), c.int("user_id"), c.int("chat_id"), c.businessConnectionID, false)
+}
+
+// handleClearHardCommand handles /clear_hard [user_id] [chat_id].
+func (b *Bot) handleClearHardCommand(ctx context.Context, c *commandCall) {
+	b.clearChatHistory(ctx, c.chatID(), c.userID(), c.int("user_id"), c.int("chat_id"), c.businessConnectionID, true)
+}
+
+// handleSetModelCommand handles /set_model <model-id>, which switches the model and saves it to the config file.
+func (b *Bot) handleSetModelCommand(ctx context.Context, c *commandCall) {
+	newModel := c.str("model-id")
+	// No upfront model validation:
+	// - The go-anthropic library constants are not enumerable at runtime (Go has no const reflection).
+	// - A live /v1/models probe would add a network round-trip and show in the API audit log.
+	// - An invalid model ID will produce a 404 on the next real message, which routes through
+	//   anthropicErrorResponse and already delivers an actionable admin-facing hint.
+	if err := b.config.PersistModel(newModel); err != nil {
+		ErrorLogger.Printf("Failed to persist model change: %v", err)
+		c.reply(fmt.Sprintf("Model updated in memory to `%s`, but failed to save to config file: %v", newModel, err))
+		return
+	}
+	InfoLogger.Printf("Model changed to %s by user %d", newModel, c.userID())
+	c.reply(fmt.Sprintf("✅ Model updated to `%s` and saved to config.", newModel))
+}
+
 func (b *Bot) clearChatHistory(ctx context.Context, chatID int64, currentUserID int64, targetUserID int64, targetChatID int64, businessConnectionID string, hardDelete bool) {
+	// Clearing "own" history wipes the whole current chat, which in a group is shared context.
+	if (targetUserID == 0 || targetUserID == currentUserID) && isGroupChatID(chatID) && !b.isGroupAdmin(ctx, chatID, currentUserID) {
+		if err := b.sendResponse(ctx, chatID, "Permission denied. Only group admins can clear the group's history.", businessConnectionID); err != nil {
+			ErrorLogger.Printf("Error sending response: %v", err)
+		}
+		return
+	}
+
 	// If targetUserID is provided and different from currentUserID, check permissions
 	if targetUserID != 0 && targetUserID != currentUserID {
 		requiredScope := ScopeHistoryClearAny
@@ -431,7 +412,7 @@ func (b *Bot) clearChatHistory(ctx context.Context, chatID int64, currentUserID
 
 	// Delete messages from the database
 	//
-	// Assumption: this bot is primarily used in private DMs, where each user's messages
+	// Assumption: cross-user clears target private DMs, where each user's messages
 	// are stored with chat_id == their own user_id — not the caller's chat_id. Scoping
 	// a cross-user delete by the caller's chatID would therefore match 0 rows.
 	//
@@ -497,18 +478,21 @@ func (b *Bot) clearChatHistory(ctx context.Context, chatID int64, currentUserID
 	// Evict the relevant in-memory cache entry so the next access rebuilds from
 	// the now-clean DB. Applies to all cases: own history, cross-user
 	// scoped to a specific chat, and bot-wide cross-user clear.
-	b.chatMemoriesMu.Lock()
+	// The rolling summary of the same chat is wiped along with it.
+	clearedChatID := targetUserID // Bot-wide clear: primary use-case is DMs where chatID == userID.
 	if targetUserID == currentUserID {
 		// Own history is always scoped to the current chat.
-		delete(b.chatMemories, chatID)
+		clearedChatID = chatID
 	} else if targetChatID != 0 {
 		// Admin cleared a specific chat — evict that chat's cache.
-		delete(b.chatMemories, targetChatID)
-	} else {
-		// Bot-wide clear: primary use-case is DMs where chatID == userID.
-		delete(b.chatMemories, targetUserID)
+		clearedChatID = targetChatID
 	}
+	b.chatMemoriesMu.Lock()
+	delete(b.chatMemories, clearedChatID)
 	b.chatMemoriesMu.Unlock()
+	if err := b.deleteChatSummary(clearedChatID); err != nil {
+		ErrorLogger.Printf("Error deleting summary for chat %d: %v", clearedChatID, err)
+	}
 
 	// Send a confirmation message
 	var confirmationMessage string
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -539,7 +540,7 @@ func TestStatsCommand(t *testing.T) {
 			command:       "/stats user abc",
 			currentUserID: adminID,
 			expectedError: true,
-			expectedMsg:   "Invalid user ID format. Usage: /stats user [user_id]",
+			expectedMsg:   "Invalid user ID format. Usage: /stats or /stats user [user_id]",
 		},
 		{
 			name:          "User provides invalid command format",
@@ -615,7 +616,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	}
 
 	// AutoMigrate the models
-	err = db.AutoMigrate(&BotModel{}, &ConfigModel{}, &Message{}, &User{}, &Role{}, &Scope{})
+	err = db.AutoMigrate(&BotModel{}, &ConfigModel{}, &Message{}, &User{}, &Role{}, &Scope{}, &GroupSettings{}, &ChatSummary{}, &RateLimitState{}, &Ban{}, &AuditEntry{}, &AccessEntry{}, &InviteCode{}, &ChatPersona{}, &UserInstructions{})
 	if err != nil {
 		t.Fatalf("Failed to migrate database schema: %v", err)
 	}
@@ -668,6 +669,19 @@ func setupBotForTest(t *testing.T, ownerID int64) (*Bot, *MockTelegramClient) {
 	return b, mockTgClient
 }
 
+// commandUpdate builds an update with a message userID sends in chatID, a supergroup for negative
+// IDs and a private chat otherwise. Text starting with "/" gets a bot_command entity for its first word.
+func commandUpdate(userID, chatID int64, text string) *models.Update {
+	msg := &models.Message{Chat: models.Chat{ID: chatID, Type: models.ChatTypePrivate}, From: &models.User{ID: userID}, Text: text}
+	if isGroupChatID(chatID) {
+		msg.Chat.Type = models.ChatTypeSupergroup
+	}
+	if strings.HasPrefix(text, "/") {
+		msg.Entities = []models.MessageEntity{{Type: models.MessageEntityTypeBotCommand, Offset: 0, Length: len(strings.Fields(text)[0])}}
+	}
+	return &models.Update{Message: msg}
+}
+
 // TestAnthropicErrorResponse verifies that model-deprecation errors surface actionable
 // details only to admin/owner, and that regular users and non-model errors always get
 // the generic fallback.
@@ -843,6 +857,45 @@ func TestSetModelCommand(t *testing.T) { //NOSONAR go:S100 -- underscore separat
 	})
 }
 
+// TestHandleUpdate_ProviderReply verifies that the reply produced by the configured LLM provider
+// is sent to the user and persisted, and that the provider receives the bot's model and history.
+func TestHandleUpdate_ProviderReply(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, mockTgClient := setupBotForTest(t, 123)
+
+	var gotReq CompletionRequest
+	b.llm = &MockLLMProvider{
+		CompleteFunc: func(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+			gotReq = req
+			return CompletionResponse{Text: "Hi from the mock", Usage: Usage{InputTokens: 10, OutputTokens: 4}}, nil
+		},
+	}
+
+	var sentMessage string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		sentMessage = params.Text
+		return &models.Message{}, nil
+	}
+
+	update := &models.Update{
+		Message: &models.Message{
+			Chat: models.Chat{ID: 789},
+			From: &models.User{ID: 789, Username: "regular"},
+			Text: "Hello",
+		},
+	}
+	b.handleUpdate(context.Background(), nil, update)
+
+	assert.Equal(t, "Hi from the mock", sentMessage)
+	assert.Equal(t, "claude-3-5-haiku-latest", gotReq.Model)
+	if assert.Len(t, gotReq.Messages, 1) {
+		assert.Equal(t, "Hello", *gotReq.Messages[0].Content[0].Text)
+	}
+
+	var stored Message
+	assert.NoError(t, b.db.Where("chat_id = ? AND is_user = ?", 789, false).First(&stored).Error)
+	assert.Equal(t, "Hi from the mock", stored.Text)
+}
+
 // TestHasScope verifies that scope checks honour role assignments and the owner bypass.
 func TestHasScope(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
 	const ownerID int64 = 100
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// helpLocale holds the /help texts of one language. Descriptions are keyed by command name;
+// commands without a translation fall back to their English Description.
+type helpLocale struct {
+	heading      string
+	footer       string
+	usage        string
+	example      string
+	unavailable  string // Formatted with the command name
+	descriptions map[string]string
+}
+
+// englishHelp is the default locale; its descriptions come from the commands themselves.
+var englishHelp = helpLocale{
+	heading:     "📖 Commands you can use:",
+	footer:      "Send /help <command> for details and examples.",
+	usage:       "Usage",
+	example:     "Example",
+	unavailable: "There is no command /%s you can use. Send /help for the list.",
+}
+
+// helpLocales maps Telegram language codes to translations of the /help texts.
+var helpLocales = map[string]helpLocale{
+	"de": {
+		heading:     "📖 Befehle, die du verwenden kannst:",
+		footer:      "Sende /help <Befehl> für Details und Beispiele.",
+		usage:       "Verwendung",
+		example:     "Beispiel",
+		unavailable: "Es gibt keinen Befehl /%s, den du verwenden kannst. Sende /help für die Liste.",
+		descriptions: map[string]string{
+			"help":           "Zeigt die Befehle, die du verwenden kannst",
+			"stats":          "Bot-Statistiken anzeigen",
+			"whoami":         "Deine Benutzerinformationen anzeigen",
+			"clear":          "Chatverlauf löschen (wiederherstellbar); Admins können Benutzer und Chat angeben",
+			"summary":        "Zusammenfassung des bisherigen Gesprächs anzeigen",
+			"persona":        "Personas anzeigen oder diesen Chat auf eine umstellen",
+			"instructions":   "Deine eigenen Anweisungen an den Bot anzeigen, setzen oder löschen",
+			"limits":         "Deine Limits und verbleibenden Nachrichten anzeigen; Admins können einen Benutzer angeben",
+			"clear_hard":     "Chatverlauf endgültig löschen; Admins können Benutzer und Chat angeben",
+			"set_model":      "KI-Modell wechseln",
+			"ban":            "Benutzer sperren",
+			"unban":          "Sperre aufheben und Limits zurücksetzen",
+			"promote":        "Einem Benutzer eine Rolle geben, standardmäßig admin",
+			"demote":         "Benutzer auf die Rolle user zurücksetzen",
+			"users":          "Benutzer und ihre Rollen auflisten",
+			"roles":          "Rollen und ihre Berechtigungen auflisten",
+			"role_create":    "Eigene Rolle anlegen",
+			"role_delete":    "Unbenutzte eigene Rolle löschen",
+			"role_grant":     "Einer Rolle Berechtigungen erteilen",
+			"role_revoke":    "Einer Rolle Berechtigungen entziehen",
+			"transfer_owner": "Den Bot an einen anderen Benutzer übergeben (nur Besitzer)",
+			"access":         "Zugriffsmodus, Erlaubt-/Sperrlisten und Einladungscodes anzeigen",
+			"allow":          "Benutzer zur Erlaubtliste hinzufügen",
+			"disallow":       "Benutzer von der Erlaubtliste entfernen",
+			"deny":           "Benutzer stillschweigend ignorieren",
+			"undeny":         "Benutzer von der Sperrliste entfernen",
+			"invite":         "Einladungslink erstellen",
+			"group":          "Verhalten des Bots in dieser Gruppe anzeigen oder ändern",
+		},
+	},
+}
+
+// helpLocaleFor returns the /help texts for a Telegram language code such as "de" or "pt-br",
+// falling back to English.
+func helpLocaleFor(languageCode string) helpLocale {
+	lang, _, _ := strings.Cut(strings.ToLower(languageCode), "-")
+	if locale, ok := helpLocales[lang]; ok {
+		return locale
+	}
+	return englishHelp
+}
+
+// describe returns the command's description in the locale.
+func (l helpLocale) describe(cmd *Command) string {
+	if text, ok := l.descriptions[cmd.Name]; ok {
+		return text
+	}
+	return cmd.Description
+}
+
+// canRun reports whether the caller may run a command, which decides whether /help shows it.
+func (b *Bot) canRun(ctx context.Context, c *commandCall, cmd *Command) bool {
+	if cmd.Scope != "" && !b.hasScope(c.userID(), cmd.Scope) {
+		return false
+	}
+	return cmd.Visible == nil || cmd.Visible(b, ctx, c)
+}
+
+// handleHelpCommand handles /help [command]: it lists the commands the caller can run, or shows
+// the usage and example of one of them.
+func (b *Bot) handleHelpCommand(ctx context.Context, c *commandCall) {
+	locale := helpLocaleFor(c.message.From.LanguageCode)
+
+	if c.has("command") {
+		name := strings.TrimPrefix(c.str("command"), "/")
+		name, _, _ = strings.Cut(name, "@")
+		cmd, ok := b.commands.Get(strings.ToLower(name))
+		if !ok || !b.canRun(ctx, c, cmd) {
+			c.reply(fmt.Sprintf(locale.unavailable, name))
+			return
+		}
+		text := fmt.Sprintf("/%s — %s\n%s: %s", cmd.Name, locale.describe(cmd), locale.usage, cmd.usage())
+		if cmd.Example != "" {
+			text += fmt.Sprintf("\n%s: %s", locale.example, cmd.Example)
+		}
+		c.reply(text)
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(locale.heading)
+	for _, cmd := range b.commands.All() {
+		if b.canRun(ctx, c, cmd) {
+			fmt.Fprintf(&sb, "\n%s — %s", cmd.usage(), locale.describe(cmd))
+		}
+	}
+	sb.WriteString("\n\n" + locale.footer)
+	c.reply(sb.String())
+}
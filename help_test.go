@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHelpCommand verifies that /help lists only the commands the caller can run, and that
+// /help <command> shows the usage and example in the caller's language.
+func TestHelpCommand(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	var lastSent string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+	help := func(userID int64, language, text string) string {
+		msg := commandUpdate(userID, userID, text).Message
+		msg.From.LanguageCode = language
+		lastSent = ""
+		assert.True(t, b.dispatchCommand(context.Background(), msg, ""))
+		return lastSent
+	}
+
+	// Regular users only see what they can run.
+	text := help(789, "en", "/help")
+	assert.Contains(t, text, "/stats or /stats user [user_id] — Get bot statistics")
+	assert.Contains(t, text, "/limits [user_id]")
+	assert.NotContains(t, text, "/ban")
+	assert.NotContains(t, text, "/transfer_owner")
+	assert.NotContains(t, text, "/group")
+
+	// The owner sees the admin commands, but /group only applies in groups.
+	text = help(123, "en", "/help")
+	assert.Contains(t, text, "/ban <user_id> <duration|permanent> — Ban a user")
+	assert.Contains(t, text, "/transfer_owner")
+	assert.NotContains(t, text, "/group")
+
+	// Details, including the example; the slash and bot mention are optional.
+	assert.Equal(t, "/ban — Ban a user\nUsage: /ban <user_id> <duration|permanent>\nExample: /ban 12345 12h or /ban 12345 7d",
+		help(123, "en", "/help /ban@test_bot"))
+	assert.Equal(t, "There is no command /ban you can use. Send /help for the list.", help(789, "en", "/help ban"))
+	assert.Equal(t, "There is no command /nope you can use. Send /help for the list.", help(123, "", "/help nope"))
+
+	// Translated where a locale exists, English otherwise.
+	assert.Equal(t, "/ban — Benutzer sperren\nVerwendung: /ban <user_id> <duration|permanent>\nBeispiel: /ban 12345 12h or /ban 12345 7d",
+		help(123, "de-AT", "/help ban"))
+	assert.Contains(t, help(789, "de", "/help"), "Befehle, die du verwenden kannst")
+	assert.Contains(t, help(789, "fr", "/help"), "Commands you can use")
+}
+
+// TestHelpLocales verifies that every locale translates every built-in command.
+func TestHelpLocales(t *testing.T) {
+	for lang, locale := range helpLocales {
+		for _, cmd := range builtinCommands() {
+			assert.Contains(t, locale.descriptions, cmd.Name, "locale %s", lang)
+		}
+	}
+}
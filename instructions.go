@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultMaxInstructions is the length limit of a user's instructions when max_instructions is not set.
+const defaultMaxInstructions = 1000
+
+// maxInstructions returns the configured length limit of a user's instructions, in characters.
+func (b *Bot) maxInstructions() int {
+	if b.config.MaxInstructions > 0 {
+		return b.config.MaxInstructions
+	}
+	return defaultMaxInstructions
+}
+
+// userInstructions returns the instructions a user set for this bot, or "" when there are none.
+func (b *Bot) userInstructions(userID int64) string {
+	var instructions UserInstructions
+	err := b.db.Where("bot_id = ? AND user_id = ?", b.botID, userID).First(&instructions).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			ErrorLogger.Printf("Error loading the instructions of user %d: %v", userID, err)
+		}
+		return ""
+	}
+	return instructions.Text
+}
+
+// handleInstructionsCommand handles /instructions: "set <text>" stores the caller's instructions,
+// "show" and "clear" act on the caller's own, or on another user's with instructions:manage.
+func (b *Bot) handleInstructionsCommand(ctx context.Context, c *commandCall) {
+	userID := c.userID()
+	action := c.str("show|set|clear")
+
+	if action == "set" {
+		// Taken from the message as sent so line breaks survive.
+		text := strings.TrimSpace(strings.TrimPrefix(c.args, action))
+		if text == "" {
+			c.reply("Usage: /instructions set <text>")
+			return
+		}
+		if n := utf8.RuneCountInString(text); n > b.maxInstructions() {
+			c.reply(fmt.Sprintf("❌ Your instructions are %d characters long; the limit is %d.", n, b.maxInstructions()))
+			return
+		}
+		err := b.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "bot_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"updated_at", "text"}),
+		}).Create(&UserInstructions{BotID: b.botID, UserID: userID, Text: text}).Error
+		if err != nil {
+			ErrorLogger.Printf("Error saving the instructions of user %d: %v", userID, err)
+			c.reply("Sorry, I couldn't save your instructions.")
+			return
+		}
+		InfoLogger.Printf("[%s] User %d set their instructions (%d characters)", b.config.ID, userID, utf8.RuneCountInString(text))
+		c.reply("✅ Saved. I'll follow your instructions from your next message on.")
+		return
+	}
+	if action != "show" && action != "clear" {
+		c.reply("Usage: /instructions show|clear [user_id] or /instructions set <text>")
+		return
+	}
+
+	targetID := userID
+	if len(c.rest) > 0 {
+		id, err := strconv.ParseInt(c.rest[0], 10, 64)
+		if err != nil || len(c.rest) > 1 {
+			c.reply(fmt.Sprintf("Invalid user ID format. Usage: /instructions %s [user_id]", action))
+			return
+		}
+		if id != userID && !b.hasScope(userID, ScopeInstructionsManage) {
+			c.reply("Permission denied. You can only see and clear your own instructions.")
+			return
+		}
+		targetID = id
+	}
+	whose := "Your"
+	if targetID != userID {
+		whose = fmt.Sprintf("User %d's", targetID)
+	}
+
+	if action == "show" {
+		text := b.userInstructions(targetID)
+		if text == "" {
+			c.reply(fmt.Sprintf("%s instructions are empty. Set them with /instructions set <text>.", whose))
+			return
+		}
+		c.reply(fmt.Sprintf("📝 %s instructions:\n%s", whose, text))
+		return
+	}
+
+	result := b.db.Where("bot_id = ? AND user_id = ?", b.botID, targetID).Delete(&UserInstructions{})
+	if result.Error != nil {
+		ErrorLogger.Printf("Error clearing the instructions of user %d: %v", targetID, result.Error)
+		c.reply("Sorry, I couldn't clear the instructions.")
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.reply(fmt.Sprintf("%s instructions are already empty.", whose))
+		return
+	}
+	InfoLogger.Printf("[%s] User %d cleared the instructions of user %d", b.config.ID, userID, targetID)
+	c.reply(fmt.Sprintf("✅ %s instructions are cleared.", whose))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUserInstructions verifies /instructions set, show and clear, the length limit, the admin
+// scope for other users' instructions and that the instructions reach the system prompt.
+func TestUserInstructions(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	var lastSent string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+	send := func(userID int64, text string) {
+		assert.True(t, b.dispatchCommand(context.Background(), commandUpdate(userID, userID, text).Message, ""))
+	}
+	b.config.SystemPrompts = map[string]string{"default": "You are helpful.", "avoid_sensitive": "Be careful."}
+	_, err := b.getOrCreateUser(789, "regular", false)
+	assert.NoError(t, err)
+
+	send(789, "/instructions show")
+	assert.Equal(t, "Your instructions are empty. Set them with /instructions set <text>.", lastSent)
+
+	send(789, "/instructions set Call me Sam.\nKeep answers short.")
+	assert.Contains(t, lastSent, "Saved")
+	send(789, "/instructions show")
+	assert.Equal(t, "📝 Your instructions:\nCall me Sam.\nKeep answers short.", lastSent)
+
+	// Added after the bot's prompt, for this user only.
+	request := b.buildCompletionRequest(context.Background(), 789, 789, nil, false, false, false, "", "", "", false, "", 0)
+	assert.Contains(t, request.System, "Be careful.\n\nThe user's own instructions")
+	assert.True(t, strings.HasSuffix(request.System, "Call me Sam.\nKeep answers short."))
+	request = b.buildCompletionRequest(context.Background(), 789, 456, nil, false, false, false, "", "", "", false, "", 0)
+	assert.NotContains(t, request.System, "Call me Sam.")
+
+	b.config.MaxInstructions = 10
+	send(789, "/instructions set This is far too long.")
+	assert.Equal(t, "❌ Your instructions are 21 characters long; the limit is 10.", lastSent)
+
+	// Only holders of instructions:manage can see and clear other users' instructions.
+	send(456, "/instructions show 789")
+	assert.Contains(t, lastSent, "Permission denied")
+	send(123, "/instructions show 789")
+	assert.Equal(t, "📝 User 789's instructions:\nCall me Sam.\nKeep answers short.", lastSent)
+	send(123, "/instructions clear 789")
+	assert.Equal(t, "✅ User 789's instructions are cleared.", lastSent)
+	send(789, "/instructions clear")
+	assert.Equal(t, "Your instructions are already empty.", lastSent)
+
+	send(789, "/instructions set")
+	assert.Equal(t, "Usage: /instructions set <text>", lastSent)
+	send(789, "/instructions edit")
+	assert.Contains(t, lastSent, "Usage: /instructions show|clear [user_id] or /instructions set <text>")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/liushuangls/go-anthropic/v2"
+)
+
+// Provider identifiers accepted in the "provider" config field.
+const (
+	ProviderAnthropic = "anthropic"
+	ProviderOpenAI    = "openai"
+)
+
+// LLMProvider is the model backend used to generate assistant replies.
+// Conversations are expressed with the anthropic message types already used for chat
+// context; providers for other vendors translate them into their own wire format.
+// Implementations must wrap a missing/deprecated model error with ErrModelNotFound so
+// anthropicErrorResponse can surface it to admins regardless of the vendor.
+type LLMProvider interface {
+	Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error)
+}
+
+// StreamingProvider is implemented by providers that can deliver a completion incrementally.
+// onDelta receives each new text fragment in order; the returned response carries the full text.
+type StreamingProvider interface {
+	CompleteStream(ctx context.Context, req CompletionRequest, onDelta func(string)) (CompletionResponse, error)
+}
+
+// TokenCounter is implemented by providers that can count the input tokens of a request exactly.
+type TokenCounter interface {
+	CountTokens(ctx context.Context, req CompletionRequest) (int, error)
+}
+
+// CompletionRequest is a single provider-agnostic completion call.
+type CompletionRequest struct {
+	Model       string
+	System      string
+	Messages    []anthropic.Message
+	MaxTokens   int
+	Temperature *float32
+	Tools       []anthropic.ToolDefinition // Optional; when set the model may answer with ToolCalls
+}
+
+// CompletionResponse carries the assistant text and the token usage reported by the provider.
+// ToolCalls is non-empty when the model asks for tools to be run before it can answer.
+type CompletionResponse struct {
+	Text      string
+	ToolCalls []ToolCall
+	Usage     Usage
+}
+
+// ToolCall is a single tool invocation requested by the model.
+type ToolCall struct {
+	ID    string
+	Name  string
+	Input json.RawMessage
+}
+
+// Usage is the token accounting reported for one completion call.
+// Cache fields are zero for providers that do not report prompt caching.
+type Usage struct {
+	InputTokens              int
+	OutputTokens             int
+	CacheCreationInputTokens int
+	CacheReadInputTokens     int
+}
+
+// Add accumulates o into u, e.g. across the rounds of a tool-use loop.
+func (u *Usage) Add(o Usage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.CacheCreationInputTokens += o.CacheCreationInputTokens
+	u.CacheReadInputTokens += o.CacheReadInputTokens
+}
+
+// newLLMProvider builds the provider selected by config.Provider (Anthropic by default).
+func newLLMProvider(config BotConfig) (LLMProvider, error) {
+	switch config.Provider {
+	case "", ProviderAnthropic:
+		return newAnthropicProvider(config.AnthropicAPIKey), nil
+	case ProviderOpenAI:
+		return newOpenAIProvider(config.OpenAIBaseURL, config.OpenAIAPIKey), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q", config.Provider)
+	}
+}
@@ -0,0 +1,45 @@
+// llm_provider_mock.go
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// MockLLMProvider is a mock implementation of LLMProvider for testing.
+type MockLLMProvider struct {
+	CompleteFunc       func(ctx context.Context, req CompletionRequest) (CompletionResponse, error)
+	CompleteStreamFunc func(ctx context.Context, req CompletionRequest, onDelta func(string)) (CompletionResponse, error)
+	CountTokensFunc    func(ctx context.Context, req CompletionRequest) (int, error)
+}
+
+// Complete returns the result of CompleteFunc, or an error when it is not set so that
+// tests exercise the same fallback path as an unreachable provider.
+func (m *MockLLMProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	if m.CompleteFunc != nil {
+		return m.CompleteFunc(ctx, req)
+	}
+	return CompletionResponse{}, errors.New("mock provider: no response configured")
+}
+
+// CompleteStream returns the result of CompleteStreamFunc, falling back to Complete
+// (with the whole text delivered as a single delta) when it is not set.
+func (m *MockLLMProvider) CompleteStream(ctx context.Context, req CompletionRequest, onDelta func(string)) (CompletionResponse, error) {
+	if m.CompleteStreamFunc != nil {
+		return m.CompleteStreamFunc(ctx, req, onDelta)
+	}
+	resp, err := m.Complete(ctx, req)
+	if err == nil {
+		onDelta(resp.Text)
+	}
+	return resp, err
+}
+
+// CountTokens returns the result of CountTokensFunc, or an error when it is not set so that
+// callers fall back to local estimates.
+func (m *MockLLMProvider) CountTokens(ctx context.Context, req CompletionRequest) (int, error) {
+	if m.CountTokensFunc != nil {
+		return m.CountTokensFunc(ctx, req)
+	}
+	return 0, errors.New("mock provider: token counting not configured")
+}
@@ -33,6 +33,26 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer cancel()
 
+	// Create one shared webhook server per distinct listen address; bots are routed by path.
+	webhookServers := make(map[string]*webhookServer)
+	for _, cfg := range configs {
+		if cfg.Transport != TransportWebhook {
+			continue
+		}
+		if _, exists := webhookServers[cfg.WebhookListenAddr]; !exists {
+			webhookServers[cfg.WebhookListenAddr] = newWebhookServer(cfg.WebhookListenAddr)
+		}
+	}
+	for _, srv := range webhookServers {
+		wg.Add(1)
+		go func(srv *webhookServer) {
+			defer wg.Done()
+			if err := srv.Run(ctx); err != nil {
+				ErrorLogger.Printf("Webhook server on %s failed: %v", srv.addr, err)
+			}
+		}(srv)
+	}
+
 	// Initialize and start each bot
 	for _, config := range configs {
 		wg.Add(1)
@@ -47,11 +67,15 @@ func main() {
 				return
 			}
 
-			// Start the bot in a separate goroutine
-			go bot.Start(ctx)
+			if cfg.Transport == TransportWebhook {
+				if err := bot.registerWebhook(webhookServers[cfg.WebhookListenAddr]); err != nil {
+					ErrorLogger.Printf("Error registering webhook for bot %s: %v", cfg.ID, err)
+					return
+				}
+			}
 
-			// Keep the bot running until the context is cancelled
-			<-ctx.Done()
+			// Run the bot until the context is cancelled (webhook bots also clean up their webhook)
+			bot.Start(ctx)
 
 			InfoLogger.Printf("Bot %s stopped", cfg.ID)
 		}(config)
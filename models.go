@@ -6,6 +6,14 @@ import (
 	"gorm.io/gorm"
 )
 
+// KeyedModel is gorm.Model without DeletedAt, for tables with a unique key that are written with
+// upserts. A soft-deleted row would keep holding its key, so rows of these tables are deleted for good.
+type KeyedModel struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
 type BotModel struct {
 	gorm.Model
 	Identifier string `gorm:"uniqueIndex"` // Renamed from ID to Identifier
@@ -33,6 +41,7 @@ type Message struct {
 	ChatID         int64     `gorm:"index"`
 	UserID         int64     `gorm:"index"`
 	Username       string    `gorm:"index"`
+	DisplayName    string    // Sender's first and last name; used to label speakers in group chats
 	UserRole       string    // Store the role as a string
 	Text           string    `gorm:"type:text"`
 	Timestamp      time.Time `gorm:"index"`
@@ -40,8 +49,85 @@ type Message struct {
 	StickerFileID  string
 	StickerPNGFile string
 	StickerEmoji   string         // Store the emoji associated with the sticker
+	ImageFileID    string         // Telegram file ID of an attached photo or image document
+	ImageMediaType string         // MIME type of the attached image, e.g. "image/jpeg"
 	DeletedAt      gorm.DeletedAt `gorm:"index"` // Add soft delete field
 	AnsweredOn     *time.Time     `gorm:"index"` // Tracks when a user message was answered (NULL for assistant messages and unanswered user messages)
+
+	// Usage of the model calls behind an assistant message; zero for user and canned messages.
+	BilledUserID        int64  `gorm:"index"` // User whose message the reply answered
+	ModelName           string // Model that generated the reply
+	InputTokens         int
+	OutputTokens        int
+	CacheCreationTokens int
+	CacheReadTokens     int
+	Cost                float64 // Estimated USD cost from the price table at the time of the reply
+}
+
+// GroupSettings holds the per-group behaviour of a bot, managed by the group's admins via /group.
+// Groups without a row use the defaults from defaultGroupSettings.
+type GroupSettings struct {
+	gorm.Model
+	BotID         uint   `gorm:"uniqueIndex:idx_group_bot_chat"`
+	ChatID        int64  `gorm:"uniqueIndex:idx_group_bot_chat"`
+	Enabled       bool   // Whether the bot replies in this group at all
+	TriggerMode   string // GroupTriggerMention, GroupTriggerKeyword or GroupTriggerAll
+	AllowedTopics string // Comma-separated forum topic IDs the bot replies in (0 = General); empty allows all
+}
+
+// ChatPersona is the persona a chat switched to with /persona. Chats without a row use
+// default_persona, or the bot's own settings when that is unset.
+type ChatPersona struct {
+	KeyedModel
+	BotID   uint   `gorm:"uniqueIndex:idx_persona_bot_chat"`
+	ChatID  int64  `gorm:"uniqueIndex:idx_persona_bot_chat"`
+	Persona string // Key into the bot's personas
+}
+
+// UserInstructions are a user's own preferences for a bot, set with /instructions and added to
+// the system prompt of their requests.
+type UserInstructions struct {
+	KeyedModel
+	BotID  uint   `gorm:"uniqueIndex:idx_instructions_bot_user"`
+	UserID int64  `gorm:"uniqueIndex:idx_instructions_bot_user"` // Telegram ID
+	Text   string `gorm:"type:text"`
+}
+
+// ChatSummary is the rolling summary of the messages of a chat that no longer fit in chat memory.
+type ChatSummary struct {
+	KeyedModel
+	BotID         uint   `gorm:"uniqueIndex:idx_summary_bot_chat"`
+	ChatID        int64  `gorm:"uniqueIndex:idx_summary_bot_chat"`
+	Summary       string `gorm:"type:text"`
+	LastMessageID uint   // ID of the newest message folded into Summary
+}
+
+// RateLimitState is the persisted state of a user's rate limiter, so message limits and
+// temporary bans survive restarts. Rows of quiet, unbanned users are garbage-collected.
+type RateLimitState struct {
+	KeyedModel
+	BotID           uint  `gorm:"uniqueIndex:idx_rate_limit_bot_user"`
+	UserID          int64 `gorm:"uniqueIndex:idx_rate_limit_bot_user"`
+	HourlyLimit     int   // Limits of the user's tier when the state was saved
+	DailyLimit      int
+	HourlyTokens    float64 // Messages left in the hourly bucket as of LastSeen
+	DailyTokens     float64 // Messages left in the daily bucket as of LastSeen
+	LastHourlyReset time.Time
+	LastDailyReset  time.Time
+	BanUntil        time.Time
+	Violations      int // Rate limit violations since FirstViolation
+	FirstViolation  time.Time
+	LastSeen        time.Time `gorm:"index"`
+}
+
+// Ban blocks a user from a bot until Until, or permanently when Until is nil.
+// Banned users' messages are ignored before they are stored.
+type Ban struct {
+	KeyedModel
+	BotID    uint       `gorm:"uniqueIndex:idx_ban_bot_user"`
+	UserID   int64      `gorm:"uniqueIndex:idx_ban_bot_user"`
+	Until    *time.Time `gorm:"index"`
+	BannedBy int64      // Telegram ID of the admin who issued the ban
 }
 
 type ChatMemory struct {
@@ -60,7 +146,22 @@ const (
 	ScopeHistoryClearHardAny = "history:clear_hard:any"
 	ScopeModelSet            = "model:set"
 	ScopeUserPromote         = "user:promote"
+	ScopeUserDemote          = "user:demote"
+	ScopeUserList            = "user:list"
+	ScopeRoleView            = "role:view"
+	ScopeRoleManage          = "role:manage"
+	ScopeUserAccess          = "user:access"
 	ScopeTTSUse              = "tts:use"
+	ScopeToolTime            = "tool:time"
+	ScopeToolUserStats       = "tool:user_stats"
+	ScopeToolCalculator      = "tool:calculator"
+	ScopeVisionUse           = "vision:use"
+	ScopeRateLimitBypass     = "ratelimit:bypass"
+	ScopeLimitsViewOwn       = "limits:view:own"
+	ScopeLimitsViewAny       = "limits:view:any"
+	ScopeUserBan             = "user:ban"
+	ScopeInstructionsManage  = "instructions:manage"
+	ScopePersonaPrefix       = "persona:" // Followed by a persona name; lets a role switch to that persona
 )
 
 type Scope struct {
@@ -68,12 +169,45 @@ type Scope struct {
 	Name string `gorm:"uniqueIndex"`
 }
 
+// Role is a named set of scopes. Roles with a zero BotID are global and shared by every bot; a bot
+// sees its own roles plus the global ones whose names it doesn't define itself.
 type Role struct {
 	gorm.Model
-	Name   string  `gorm:"uniqueIndex"`
+	BotID  uint    `gorm:"not null;default:0;uniqueIndex:idx_role_bot_name"`
+	Name   string  `gorm:"uniqueIndex:idx_role_bot_name"`
 	Scopes []Scope `gorm:"many2many:role_scopes;"`
 }
 
+// AccessEntry puts a user on a bot's allowlist or denylist.
+type AccessEntry struct {
+	KeyedModel
+	BotID   uint   `gorm:"uniqueIndex:idx_access_bot_user"`
+	UserID  int64  `gorm:"uniqueIndex:idx_access_bot_user"`
+	List    string `gorm:"index"` // accessAllow or accessDeny
+	AddedBy int64  // Telegram ID of the admin who added the entry, or of the invite's creator
+}
+
+// InviteCode grants access to a bot in invite-only mode when redeemed with /start <code>.
+type InviteCode struct {
+	gorm.Model
+	BotID     uint   `gorm:"index"`
+	Code      string `gorm:"uniqueIndex"`
+	MaxUses   int
+	Uses      int
+	ExpiresAt *time.Time // nil means the code doesn't expire
+	CreatedBy int64
+}
+
+// AuditEntry records a sensitive administrative action, such as an ownership transfer.
+type AuditEntry struct {
+	gorm.Model
+	BotID    uint   `gorm:"index"`
+	ActorID  int64  // Telegram ID of the user who performed the action
+	Action   string `gorm:"index"`
+	TargetID int64  // Telegram ID of the user the action applies to
+	Details  string
+}
+
 type User struct {
 	gorm.Model
 	BotID      uint  `gorm:"uniqueIndex:idx_user_bot;index"`    // Foreign key to BotModel
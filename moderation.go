@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const banDurationHelp = "Durations are a number of days such as 7d, a duration such as 12h, or permanent."
+
+// timeLayout is how ban and limit expiry times are shown to admins.
+const timeLayout = "2006-01-02 15:04 MST"
+
+// activeBan returns the ban of a user that is in force now, if any. Lookup errors are logged and
+// treated as not banned.
+func (b *Bot) activeBan(userID int64) (Ban, bool) {
+	var ban Ban
+	err := b.db.Where("bot_id = ? AND user_id = ? AND (until IS NULL OR until > ?)", b.botID, userID, b.clock.Now()).
+		First(&ban).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			ErrorLogger.Printf("Error checking ban of user %d: %v", userID, err)
+		}
+		return Ban{}, false
+	}
+	return ban, true
+}
+
+// parseBanDuration parses a ban length: "permanent", a number of days such as "7d", or a Go
+// duration such as "12h". A nil result means permanent.
+func parseBanDuration(s string) (*time.Duration, error) {
+	if s == "permanent" {
+		return nil, nil
+	}
+	var d time.Duration
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(s); err != nil {
+			return nil, fmt.Errorf("invalid duration %q", s)
+		}
+	}
+	if d <= 0 {
+		return nil, fmt.Errorf("duration %q must be positive", s)
+	}
+	return &d, nil
+}
+
+// limiterStatus returns the messages left in a user's buckets and the expiry of their
+// temporary rate limit ban (zero when not banned).
+func (b *Bot) limiterStatus(userID int64, tier RateLimitTier) (hourly, daily int, banUntil time.Time) {
+	b.userLimitersMu.RLock()
+	defer b.userLimitersMu.RUnlock()
+
+	now := b.clock.Now()
+	hourly, daily = tier.MessagesPerHour, tier.MessagesPerDay
+	limiter, exists := b.userLimiters[userID]
+	if !exists {
+		return hourly, daily, time.Time{}
+	}
+	if now.Before(limiter.banUntil) {
+		banUntil = limiter.banUntil
+	}
+	if limiter.tier != tier {
+		// The next message starts fresh buckets for the new tier.
+		return hourly, daily, banUntil
+	}
+	if now.Sub(limiter.lastHourlyReset) < time.Hour {
+		hourly = int(limiter.hourlyLimiter.TokensAt(now))
+	}
+	if now.Sub(limiter.lastDailyReset) < 24*time.Hour {
+		daily = int(limiter.dailyLimiter.TokensAt(now))
+	}
+	return hourly, daily, banUntil
+}
+
+// resetUserLimiter forgets a user's rate limiter, lifting a temporary ban and refilling the buckets.
+func (b *Bot) resetUserLimiter(userID int64) error {
+	b.userLimitersMu.Lock()
+	defer b.userLimitersMu.Unlock()
+	delete(b.userLimiters, userID)
+	return b.db.Where("bot_id = ? AND user_id = ?", b.botID, userID).Delete(&RateLimitState{}).Error
+}
+
+// handleLimitsCommand shows the rate limits, remaining messages and bans of the sender, or of
+// another user for holders of limits:view:any.
+func (b *Bot) handleLimitsCommand(ctx context.Context, c *commandCall) {
+	userID := c.userID()
+	targetID := userID
+	if c.has("user_id") {
+		targetID = c.int("user_id")
+	}
+
+	scope := ScopeLimitsViewOwn
+	if targetID != userID {
+		scope = ScopeLimitsViewAny
+	}
+	if !b.hasScope(userID, scope) {
+		c.reply("Permission denied. You don't have access to these limits.")
+		return
+	}
+
+	text := fmt.Sprintf("⏱ Limits for user %d:", targetID)
+	if ban, banned := b.activeBan(targetID); banned {
+		if ban.Until == nil {
+			text += "\n- Banned permanently"
+		} else {
+			text += fmt.Sprintf("\n- Banned until %s", ban.Until.Format(timeLayout))
+		}
+	}
+
+	tier, exempt := b.rateLimitTier(targetID)
+	if exempt {
+		c.reply(text + "\n- Not rate limited")
+		return
+	}
+	hourly, daily, banUntil := b.limiterStatus(targetID, tier)
+	text += fmt.Sprintf("\n- This hour: %d of %d messages left\n- Today: %d of %d messages left",
+		hourly, tier.MessagesPerHour, daily, tier.MessagesPerDay)
+	if !banUntil.IsZero() {
+		text += fmt.Sprintf("\n- Rate limited until %s", banUntil.Format(timeLayout))
+	}
+	c.reply(text)
+}
+
+// mayModerate reports whether actor may ban or unban the user with the given Telegram ID: like
+// role changes, only users whose role the actor covers, and co-owners only by the owner.
+func (b *Bot) mayModerate(actor User, targetID int64) (bool, error) {
+	target, err := b.loadUser(targetID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	target.TelegramID = targetID // Users who haven't written yet can be moderated too
+	return !(b.actsAsOwner(target) && !actor.IsOwner) && b.coversRole(actor, target.Role), nil
+}
+
+// handleBanCommand bans a user from the bot for a duration or permanently, within the caller's
+// permissions (see mayModerate).
+func (b *Bot) handleBanCommand(ctx context.Context, c *commandCall) {
+	userID, targetID := c.userID(), c.int("user_id")
+	duration, err := parseBanDuration(c.str("duration|permanent"))
+	if err != nil {
+		c.reply(fmt.Sprintf("Invalid ban duration: %v. %s", err, banDurationHelp))
+		return
+	}
+	if targetID == userID || targetID == b.ownerTelegramID() {
+		c.reply("You can't ban yourself or the owner.")
+		return
+	}
+
+	actor, err := b.loadUser(userID)
+	if err != nil {
+		ErrorLogger.Printf("Error loading user %d: %v", userID, err)
+		c.reply("Sorry, I couldn't ban that user.")
+		return
+	}
+	allowed, err := b.mayModerate(actor, targetID)
+	if err != nil {
+		ErrorLogger.Printf("Error loading user %d: %v", targetID, err)
+		c.reply("Sorry, I couldn't ban that user.")
+		return
+	}
+	if !allowed {
+		c.reply("You can only ban users within your own permissions.")
+		return
+	}
+
+	ban := Ban{BotID: b.botID, UserID: targetID, BannedBy: userID}
+	if duration != nil {
+		until := b.clock.Now().Add(*duration)
+		ban.Until = &until
+	}
+	if err := b.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "bot_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "until", "banned_by"}),
+	}).Create(&ban).Error; err != nil {
+		ErrorLogger.Printf("Error banning user %d: %v", targetID, err)
+		c.reply("Sorry, I couldn't ban that user.")
+		return
+	}
+
+	InfoLogger.Printf("[%s] User %d banned user %d (%s)", b.config.ID, userID, targetID, c.str("duration|permanent"))
+	if ban.Until == nil {
+		c.reply(fmt.Sprintf("🚫 User %d is banned permanently.", targetID))
+		return
+	}
+	c.reply(fmt.Sprintf("🚫 User %d is banned until %s.", targetID, ban.Until.Format(timeLayout)))
+}
+
+// handleUnbanCommand lifts a manual ban and any temporary rate limit ban of a user. The same
+// permissions as for banning apply, and a ban can't be lifted by someone its issuer outranks.
+func (b *Bot) handleUnbanCommand(ctx context.Context, c *commandCall) {
+	userID, targetID := c.userID(), c.int("user_id")
+
+	actor, err := b.loadUser(userID)
+	if err != nil {
+		ErrorLogger.Printf("Error loading user %d: %v", userID, err)
+		c.reply("Sorry, I couldn't unban that user.")
+		return
+	}
+	allowed, err := b.mayModerate(actor, targetID)
+	if err != nil {
+		ErrorLogger.Printf("Error loading user %d: %v", targetID, err)
+		c.reply("Sorry, I couldn't unban that user.")
+		return
+	}
+	if !allowed {
+		c.reply("You can only unban users within your own permissions.")
+		return
+	}
+
+	var ban Ban
+	err = b.db.Where("bot_id = ? AND user_id = ?", b.botID, targetID).First(&ban).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorLogger.Printf("Error loading ban of user %d: %v", targetID, err)
+		c.reply("Sorry, I couldn't unban that user.")
+		return
+	}
+	if err == nil && ban.BannedBy != userID {
+		if allowed, err = b.mayModerate(actor, ban.BannedBy); err != nil {
+			ErrorLogger.Printf("Error loading user %d: %v", ban.BannedBy, err)
+			c.reply("Sorry, I couldn't unban that user.")
+			return
+		}
+		if !allowed {
+			c.reply(fmt.Sprintf("User %d was banned by someone above your permissions.", targetID))
+			return
+		}
+	}
+
+	if err := b.db.Where("bot_id = ? AND user_id = ?", b.botID, targetID).Delete(&Ban{}).Error; err != nil {
+		ErrorLogger.Printf("Error unbanning user %d: %v", targetID, err)
+		c.reply("Sorry, I couldn't unban that user.")
+		return
+	}
+	if err := b.resetUserLimiter(targetID); err != nil {
+		ErrorLogger.Printf("Error resetting rate limits of user %d: %v", targetID, err)
+	}
+
+	InfoLogger.Printf("[%s] User %d unbanned user %d", b.config.ID, userID, targetID)
+	c.reply(fmt.Sprintf("✅ User %d is unbanned and their rate limits are reset.", targetID))
+}
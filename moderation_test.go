@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBanDuration(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantNil bool
+		wantErr bool
+	}{
+		{input: "permanent", wantNil: true},
+		{input: "12h", want: 12 * time.Hour},
+		{input: "7d", want: 7 * 24 * time.Hour},
+		{input: "30m", want: 30 * time.Minute},
+		{input: "0h", wantErr: true},
+		{input: "-1d", wantErr: true},
+		{input: "soon", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := parseBanDuration(tc.input)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tc.wantNil {
+				assert.Nil(t, got)
+				return
+			}
+			if assert.NotNil(t, got) {
+				assert.Equal(t, tc.want, *got)
+			}
+		})
+	}
+}
+
+// TestBanCommands verifies /ban, /limits and /unban, and that banned users are ignored.
+func TestBanCommands(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	var lastSent string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+	var llmCalls int
+	b.llm = &MockLLMProvider{CompleteFunc: func(context.Context, CompletionRequest) (CompletionResponse, error) {
+		llmCalls++
+		return CompletionResponse{Text: "reply"}, nil
+	}}
+	send := func(userID int64, text string) {
+		b.handleUpdate(context.Background(), nil, commandUpdate(userID, userID, text))
+	}
+
+	send(789, "hello")
+	assert.Equal(t, 1, llmCalls)
+
+	// Regular users can't ban, but can see their own limits.
+	send(789, "/ban 555 1h")
+	assert.Contains(t, lastSent, "Permission denied")
+	send(789, "/limits")
+	assert.Contains(t, lastSent, "This hour: 4 of 5 messages left")
+	send(789, "/limits 123")
+	assert.Contains(t, lastSent, "Permission denied")
+
+	send(123, "/ban 789 2d")
+	assert.Contains(t, lastSent, "🚫 User 789 is banned until")
+	send(123, "/ban 123 permanent")
+	assert.Equal(t, "You can't ban yourself or the owner.", lastSent)
+
+	// Admins can't ban co-owners or users with scopes they don't hold; the owner can.
+	b.config.CoOwnerTelegramIDs = []int64{555}
+	for _, id := range []int64{456, 555, 666} {
+		_, err := b.getOrCreateUser(id, "staff", false)
+		assert.NoError(t, err)
+	}
+	for id, role := range map[int64]string{456: "admin", 666: "owner"} {
+		assert.NoError(t, b.db.Model(&User{}).Where("telegram_id = ?", id).
+			Update("role_id", b.db.Model(&Role{}).Select("id").Where("name = ?", role)).Error)
+	}
+	send(456, "/ban 555 1h")
+	assert.Equal(t, "You can only ban users within your own permissions.", lastSent)
+	send(456, "/ban 666 1h")
+	assert.Equal(t, "You can only ban users within your own permissions.", lastSent)
+	send(555, "/ban 666 1h")
+	assert.Contains(t, lastSent, "🚫 User 666 is banned until")
+	send(123, "/ban 555 1h")
+	assert.Contains(t, lastSent, "🚫 User 555 is banned until")
+
+	// The same applies to unbanning, and admins can't lift a ban the owner placed.
+	send(456, "/unban 555")
+	assert.Equal(t, "You can only unban users within your own permissions.", lastSent)
+	send(456, "/unban 666")
+	assert.Equal(t, "You can only unban users within your own permissions.", lastSent)
+	send(456, "/unban 789")
+	assert.Equal(t, "User 789 was banned by someone above your permissions.", lastSent)
+	send(123, "/unban 555")
+	assert.Contains(t, lastSent, "User 555 is unbanned")
+
+	send(789, "hello again")
+	assert.Equal(t, 1, llmCalls, "banned users must be ignored")
+	var stored int64
+	b.db.Model(&Message{}).Where("text = ?", "hello again").Count(&stored)
+	assert.Zero(t, stored)
+
+	send(123, "/limits 789")
+	assert.Contains(t, lastSent, "Banned until")
+	send(123, "/limits")
+	assert.Contains(t, lastSent, "Not rate limited")
+
+	// A ban expires on its own.
+	b.clock.(*MockClock).Advance(49 * time.Hour)
+	send(789, "back")
+	assert.Equal(t, 2, llmCalls)
+
+	// /unban lifts a permanent ban and a temporary rate limit ban.
+	send(123, "/ban 789 permanent")
+	assert.Contains(t, lastSent, "banned permanently")
+	for i := 0; i < 6; i++ {
+		b.checkRateLimits(789)
+	}
+	send(123, "/unban 789")
+	assert.Contains(t, lastSent, "is unbanned")
+	send(789, "unbanned")
+	assert.Equal(t, 3, llmCalls)
+}
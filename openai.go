@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/liushuangls/go-anthropic/v2"
+)
+
+const openAIDefaultBaseURL = "https://api.openai.com/v1"
+
+// openAIProvider implements LLMProvider against any OpenAI-compatible
+// /chat/completions endpoint (OpenAI, OpenRouter, vLLM, Ollama, ...).
+type openAIProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+type openAIChatMessage struct {
+	Role       string           `json:"role"`
+	Content    any              `json:"content"` // string, or []openAIContentPart for messages with images
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIContentPart struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	ImageURL *openAIImageURL `json:"image_url,omitempty"`
+}
+
+type openAIImageURL struct {
+	URL string `json:"url"`
+}
+
+type openAIToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function openAIFunctionCall `json:"function"`
+}
+
+type openAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type openAITool struct {
+	Type     string            `json:"type"`
+	Function openAIFunctionDef `json:"function"`
+}
+
+type openAIFunctionDef struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+type openAIChatRequest struct {
+	Model       string              `json:"model"`
+	Messages    []openAIChatMessage `json:"messages"`
+	MaxTokens   int                 `json:"max_tokens,omitempty"`
+	Temperature *float32            `json:"temperature,omitempty"`
+	Tools       []openAITool        `json:"tools,omitempty"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIChatMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+type openAIErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+func newOpenAIProvider(baseURL, apiKey string) *openAIProvider {
+	if baseURL == "" {
+		baseURL = openAIDefaultBaseURL
+	}
+	return &openAIProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  http.DefaultClient,
+	}
+}
+
+// toOpenAIMessages flattens the system prompt and anthropic-style turns into chat messages.
+// Text blocks in one turn are joined with newlines, tool_use blocks become assistant tool_calls
+// and tool_result blocks become separate "tool" messages. Turns with base64 images are sent as
+// content parts with data URLs.
+func toOpenAIMessages(system string, messages []anthropic.Message) []openAIChatMessage {
+	out := make([]openAIChatMessage, 0, len(messages)+1)
+	if system != "" {
+		out = append(out, openAIChatMessage{Role: "system", Content: system})
+	}
+	for _, msg := range messages {
+		var parts []string
+		var images []openAIContentPart
+		var toolCalls []openAIToolCall
+		for _, content := range msg.Content {
+			switch content.Type {
+			case anthropic.MessagesContentTypeText:
+				if content.Text != nil {
+					parts = append(parts, *content.Text)
+				}
+			case anthropic.MessagesContentTypeImage:
+				if src := content.Source; src != nil && src.Type == anthropic.MessagesContentSourceTypeBase64 {
+					images = append(images, openAIContentPart{
+						Type:     "image_url",
+						ImageURL: &openAIImageURL{URL: fmt.Sprintf("data:%s;base64,%v", src.MediaType, src.Data)},
+					})
+				}
+			case anthropic.MessagesContentTypeToolUse:
+				if content.MessageContentToolUse != nil {
+					toolCalls = append(toolCalls, openAIToolCall{
+						ID:       content.MessageContentToolUse.ID,
+						Type:     "function",
+						Function: openAIFunctionCall{Name: content.MessageContentToolUse.Name, Arguments: string(content.MessageContentToolUse.Input)},
+					})
+				}
+			case anthropic.MessagesContentTypeToolResult:
+				if result := content.MessageContentToolResult; result != nil && result.ToolUseID != nil {
+					var resultParts []string
+					for _, c := range result.Content {
+						resultParts = append(resultParts, c.GetText())
+					}
+					out = append(out, openAIChatMessage{Role: "tool", ToolCallID: *result.ToolUseID, Content: strings.Join(resultParts, "\n")})
+				}
+			}
+		}
+		if len(parts) == 0 && len(images) == 0 && len(toolCalls) == 0 {
+			continue
+		}
+		role := "user"
+		if msg.Role == anthropic.RoleAssistant {
+			role = "assistant"
+		}
+		text := strings.Join(parts, "\n")
+		var content any = text
+		if len(images) > 0 {
+			content = append(images, openAIContentPart{Type: "text", Text: text})
+		}
+		out = append(out, openAIChatMessage{Role: role, Content: content, ToolCalls: toolCalls})
+	}
+	return out
+}
+
+// toOpenAITools converts anthropic tool definitions into OpenAI function tools.
+func toOpenAITools(tools []anthropic.ToolDefinition) []openAITool {
+	out := make([]openAITool, 0, len(tools))
+	for _, tool := range tools {
+		out = append(out, openAITool{
+			Type:     "function",
+			Function: openAIFunctionDef{Name: tool.Name, Description: tool.Description, Parameters: tool.InputSchema},
+		})
+	}
+	return out
+}
+
+// Complete sends the conversation to the chat completions endpoint.
+func (p *openAIProvider) Complete(ctx context.Context, req CompletionRequest) (CompletionResponse, error) {
+	body, err := json.Marshal(openAIChatRequest{
+		Model:       req.Model,
+		Messages:    toOpenAIMessages(req.System, req.Messages),
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		Tools:       toOpenAITools(req.Tools),
+	})
+	if err != nil {
+		return CompletionResponse{}, fmt.Errorf("openai marshal error: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return CompletionResponse{}, fmt.Errorf("openai request error: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return CompletionResponse{}, fmt.Errorf("openai request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errBody, _ := io.ReadAll(resp.Body)
+		var apiErr openAIErrorResponse
+		_ = json.Unmarshal(errBody, &apiErr)
+		if resp.StatusCode == http.StatusNotFound || apiErr.Error.Code == "model_not_found" {
+			return CompletionResponse{}, fmt.Errorf("%w: %s", ErrModelNotFound, req.Model)
+		}
+		return CompletionResponse{}, fmt.Errorf("openai error: status %d: %s", resp.StatusCode, errBody)
+	}
+
+	var result openAIChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return CompletionResponse{}, fmt.Errorf("openai decode error: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return CompletionResponse{}, fmt.Errorf("unexpected response format from OpenAI-compatible provider")
+	}
+
+	message := result.Choices[0].Message
+	text, _ := message.Content.(string) // null when the model only calls tools
+	completion := CompletionResponse{
+		Text: text,
+		Usage: Usage{
+			InputTokens:  result.Usage.PromptTokens,
+			OutputTokens: result.Usage.CompletionTokens,
+		},
+	}
+	for _, call := range message.ToolCalls {
+		input := json.RawMessage(call.Function.Arguments)
+		if len(input) == 0 {
+			input = json.RawMessage(`{}`)
+		}
+		completion.ToolCalls = append(completion.ToolCalls, ToolCall{ID: call.ID, Name: call.Function.Name, Input: input})
+	}
+	return completion, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestOpenAIProvider_Complete verifies request translation, response parsing and usage reporting.
+func TestOpenAIProvider_Complete(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	var got openAIChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi there"}}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`))
+	}))
+	defer server.Close()
+
+	p := newOpenAIProvider(server.URL+"/v1/", "sk-test")
+	resp, err := p.Complete(context.Background(), CompletionRequest{
+		Model:  "gpt-4o-mini",
+		System: "be nice",
+		Messages: []anthropic.Message{
+			anthropic.NewUserTextMessage("hello"),
+			anthropic.NewAssistantTextMessage("hey"),
+			anthropic.NewUserTextMessage("how are you?"),
+		},
+		MaxTokens: 100,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "hi there", resp.Text)
+	assert.Equal(t, 12, resp.Usage.InputTokens)
+	assert.Equal(t, 3, resp.Usage.OutputTokens)
+
+	assert.Equal(t, "gpt-4o-mini", got.Model)
+	assert.Equal(t, 100, got.MaxTokens)
+	assert.Equal(t, []openAIChatMessage{
+		{Role: "system", Content: "be nice"},
+		{Role: "user", Content: "hello"},
+		{Role: "assistant", Content: "hey"},
+		{Role: "user", Content: "how are you?"},
+	}, got.Messages)
+}
+
+// TestOpenAIProvider_Errors verifies that missing models are classified as ErrModelNotFound
+// while other API failures are returned as plain errors.
+func TestOpenAIProvider_Errors(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	tests := []struct {
+		name          string
+		status        int
+		body          string
+		wantModelErr  bool
+		wantErrSubstr string
+	}{
+		{"404 status", http.StatusNotFound, `{"error":{"message":"no such model"}}`, true, ""},
+		{"model_not_found code", http.StatusBadRequest, `{"error":{"message":"bad","code":"model_not_found"}}`, true, ""},
+		{"server error", http.StatusInternalServerError, `{"error":{"message":"boom"}}`, false, "status 500"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			p := newOpenAIProvider(server.URL, "")
+			_, err := p.Complete(context.Background(), CompletionRequest{Model: "gone"})
+			assert.Error(t, err)
+			assert.Equal(t, tc.wantModelErr, errors.Is(err, ErrModelNotFound))
+			if tc.wantErrSubstr != "" {
+				assert.Contains(t, err.Error(), tc.wantErrSubstr)
+			}
+		})
+	}
+}
+
+// TestNewLLMProvider verifies provider selection from config.
+func TestNewLLMProvider(t *testing.T) {
+	p, err := newLLMProvider(BotConfig{})
+	assert.NoError(t, err)
+	assert.IsType(t, &anthropicProvider{}, p)
+
+	p, err = newLLMProvider(BotConfig{Provider: ProviderOpenAI})
+	assert.NoError(t, err)
+	assert.IsType(t, &openAIProvider{}, p)
+	assert.Equal(t, openAIDefaultBaseURL, p.(*openAIProvider).baseURL)
+
+	_, err = newLLMProvider(BotConfig{Provider: "bogus"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"gorm.io/gorm"
+)
+
+// ownerTransferTTL is how long a /transfer_owner request waits for both confirmations.
+const ownerTransferTTL = 10 * time.Minute
+
+const transferUsage = "Usage: /transfer_owner <user_id>, then /transfer_owner confirm once the new owner has accepted. /transfer_owner cancel aborts."
+
+// AuditActionOwnerTransfer is the audit action recorded when ownership changes hands.
+const AuditActionOwnerTransfer = "owner_transfer"
+
+// ownerTransfer is a pending handover of the bot from the owner to another user. It completes
+// once the new owner accepts and the current owner confirms.
+type ownerTransfer struct {
+	from, to int64
+	accepted bool
+	expires  time.Time
+}
+
+// isCoOwner reports whether userID is listed in co_owner_telegram_ids.
+func (b *Bot) isCoOwner(userID int64) bool {
+	return slices.Contains(b.config.CoOwnerTelegramIDs, userID)
+}
+
+// actsAsOwner reports whether u holds every scope: the owner and the configured co-owners.
+func (b *Bot) actsAsOwner(u User) bool {
+	return u.IsOwner || b.isCoOwner(u.TelegramID)
+}
+
+// ownerTelegramID returns the Telegram ID of the current owner. It starts as owner_telegram_id
+// and changes with /transfer_owner.
+func (b *Bot) ownerTelegramID() int64 {
+	var owner User
+	if err := b.db.Where("bot_id = ? AND is_owner = ?", b.botID, true).First(&owner).Error; err != nil {
+		return b.config.OwnerTelegramID
+	}
+	return owner.TelegramID
+}
+
+// pendingTransfer returns the pending transfer, dropping it once expired. The caller holds transferMu.
+func (b *Bot) pendingTransfer() *ownerTransfer {
+	if b.transfer != nil && !b.clock.Now().Before(b.transfer.expires) {
+		b.transfer = nil
+	}
+	return b.transfer
+}
+
+// handleTransferOwnerCommand drives the two-party ownership handover:
+// the owner runs /transfer_owner <user_id>, the new owner runs /transfer_owner accept, and the
+// owner completes it with /transfer_owner confirm. Either party can /transfer_owner cancel.
+func (b *Bot) handleTransferOwnerCommand(ctx context.Context, c *commandCall) {
+	userID := c.userID()
+
+	arg := c.str("user_id|accept|confirm|cancel")
+	isOwner := b.ownerTelegramID() == userID
+
+	b.transferMu.Lock()
+	defer b.transferMu.Unlock()
+	pending := b.pendingTransfer()
+
+	switch arg {
+	case "accept":
+		if pending == nil || pending.to != userID {
+			c.reply("There is no ownership transfer waiting for you.")
+			return
+		}
+		pending.accepted = true
+		b.notify(ctx, pending.from, fmt.Sprintf("User %d accepted the ownership transfer. Send /transfer_owner confirm to complete it.", userID))
+		c.reply("✅ Accepted. The transfer completes once the current owner confirms.")
+
+	case "confirm":
+		if !isOwner || pending == nil {
+			c.reply("There is no ownership transfer to confirm.")
+			return
+		}
+		if !pending.accepted {
+			c.reply(fmt.Sprintf("User %d hasn't accepted the transfer yet.", pending.to))
+			return
+		}
+		b.transfer = nil
+		if err := b.transferOwnership(ctx, pending.from, pending.to); err != nil {
+			ErrorLogger.Printf("Error transferring ownership to user %d: %v", pending.to, err)
+			c.reply("Sorry, I couldn't transfer the ownership.")
+			return
+		}
+		b.notify(ctx, pending.to, "👑 You are now the owner of this bot.")
+		c.reply(fmt.Sprintf("✅ User %d is now the owner. You keep the admin role.", pending.to))
+
+	case "cancel":
+		if pending == nil || (pending.from != userID && pending.to != userID) {
+			c.reply("There is no ownership transfer to cancel.")
+			return
+		}
+		b.transfer = nil
+		other := pending.to
+		if userID == pending.to {
+			other = pending.from
+		}
+		b.notify(ctx, other, "The ownership transfer was cancelled.")
+		c.reply("The ownership transfer is cancelled.")
+
+	default:
+		if !isOwner {
+			c.reply("Permission denied. Only the owner can transfer ownership.")
+			return
+		}
+		targetID, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			c.reply("Invalid user ID format. " + transferUsage)
+			return
+		}
+		if targetID == userID {
+			c.reply("You already own this bot.")
+			return
+		}
+		if _, banned := b.activeBan(targetID); banned {
+			c.reply(fmt.Sprintf("User %d is banned and can't become the owner.", targetID))
+			return
+		}
+		// The new owner has to be reachable to accept, which also proves they have started the bot.
+		if _, err := b.tgBot.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID: targetID,
+			Text: fmt.Sprintf("User %d wants to make you the owner of this bot. Send /transfer_owner accept within %s to accept, or /transfer_owner cancel to decline.",
+				userID, formatWait(ownerTransferTTL)),
+		}); err != nil {
+			ErrorLogger.Printf("Error asking user %d to accept ownership: %v", targetID, err)
+			c.reply(fmt.Sprintf("I couldn't reach user %d. They need to start a chat with me first.", targetID))
+			return
+		}
+		b.transfer = &ownerTransfer{from: userID, to: targetID, expires: b.clock.Now().Add(ownerTransferTTL)}
+		InfoLogger.Printf("[%s] User %d started an ownership transfer to user %d", b.config.ID, userID, targetID)
+		c.reply(fmt.Sprintf("⏳ Asked user %d to accept. Once they do, send /transfer_owner confirm to complete the transfer.", targetID))
+	}
+}
+
+// transferOwnership makes to the owner and demotes from to admin, recording an audit entry.
+func (b *Bot) transferOwnership(ctx context.Context, from, to int64) error {
+	ownerRole, err := b.getRoleByName("owner")
+	if err != nil {
+		return fmt.Errorf("owner role not found: %w", err)
+	}
+	adminRole, err := b.getRoleByName("admin")
+	if err != nil {
+		return fmt.Errorf("admin role not found: %w", err)
+	}
+	if _, err := b.getOrCreateUser(to, "", false); err != nil {
+		return err
+	}
+
+	err = b.db.Transaction(func(tx *gorm.DB) error {
+		// The old owner goes first: idx_bot_owner allows a single owner per bot.
+		if err := tx.Model(&User{}).Where("bot_id = ? AND telegram_id = ?", b.botID, from).
+			Updates(map[string]any{"is_owner": false, "role_id": adminRole.ID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&User{}).Where("bot_id = ? AND telegram_id = ?", b.botID, to).
+			Updates(map[string]any{"is_owner": true, "role_id": ownerRole.ID}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&AuditEntry{
+			BotID:    b.botID,
+			ActorID:  from,
+			Action:   AuditActionOwnerTransfer,
+			TargetID: to,
+			Details:  fmt.Sprintf("ownership transferred from %d to %d, accepted by the new owner", from, to),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	InfoLogger.Printf("[%s] Ownership transferred from user %d to user %d", b.config.ID, from, to)
+
+	for _, id := range []int64{from, to} {
+		if u, err := b.loadUser(id); err == nil {
+			b.syncUserCommands(ctx, u)
+		}
+	}
+	return nil
+}
+
+// notify sends a message to a user's private chat, logging failures.
+func (b *Bot) notify(ctx context.Context, userID int64, text string) {
+	if _, err := b.tgBot.SendMessage(ctx, &bot.SendMessageParams{ChatID: userID, Text: text}); err != nil {
+		ErrorLogger.Printf("Error notifying user %d: %v", userID, err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTransferOwner verifies the two-party /transfer_owner flow and its audit entry.
+func TestTransferOwner(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	sent := make(map[int64]string)
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		sent[params.ChatID.(int64)] = params.Text
+		return &models.Message{}, nil
+	}
+	send := func(userID int64, text string) {
+		b.handleUpdate(context.Background(), nil, commandUpdate(userID, userID, text))
+	}
+	_, err := b.getOrCreateUser(789, "regular", false)
+	assert.NoError(t, err)
+
+	send(789, "/transfer_owner 789")
+	assert.Contains(t, sent[789], "Permission denied")
+	send(123, "/transfer_owner confirm")
+	assert.Equal(t, "There is no ownership transfer to confirm.", sent[123])
+
+	// A request expires unanswered.
+	send(123, "/transfer_owner 789")
+	assert.Contains(t, sent[789], "wants to make you the owner")
+	b.clock.(*MockClock).Advance(ownerTransferTTL)
+	send(789, "/transfer_owner accept")
+	assert.Equal(t, "There is no ownership transfer waiting for you.", sent[789])
+
+	// The owner can't confirm before the new owner accepts.
+	send(123, "/transfer_owner 789")
+	send(123, "/transfer_owner confirm")
+	assert.Equal(t, "User 789 hasn't accepted the transfer yet.", sent[123])
+	send(789, "/transfer_owner accept")
+	assert.Contains(t, sent[123], "accepted the ownership transfer")
+	send(123, "/transfer_owner confirm")
+	assert.Contains(t, sent[123], "User 789 is now the owner")
+	assert.Contains(t, sent[789], "You are now the owner")
+
+	assert.Equal(t, int64(789), b.ownerTelegramID())
+	oldOwner, err := b.loadUser(123)
+	assert.NoError(t, err)
+	assert.False(t, oldOwner.IsOwner)
+	assert.Equal(t, "admin", oldOwner.Role.Name)
+	assert.True(t, b.hasScope(789, ScopeRoleManage))
+
+	var entry AuditEntry
+	assert.NoError(t, b.db.Where("bot_id = ? AND action = ?", b.botID, AuditActionOwnerTransfer).First(&entry).Error)
+	assert.Equal(t, int64(123), entry.ActorID)
+	assert.Equal(t, int64(789), entry.TargetID)
+
+	// The new owner can't be banned by the previous one.
+	send(123, "/ban 789 1h")
+	assert.Equal(t, "You can't ban yourself or the owner.", sent[123])
+}
+
+// TestCoOwners verifies that co-owners hold every scope but can't remove the owner.
+func TestCoOwners(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	b.config.CoOwnerTelegramIDs = []int64{456}
+	var lastSent string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+	_, err := b.getOrCreateUser(456, "co", false)
+	assert.NoError(t, err)
+	_, err = b.getOrCreateUser(789, "regular", false)
+	assert.NoError(t, err)
+
+	assert.True(t, b.hasScope(456, ScopeRoleManage))
+	assert.True(t, b.hasScope(456, ScopeRateLimitBypass))
+	_, exempt := b.rateLimitTier(456)
+	assert.True(t, exempt)
+	assert.False(t, b.hasScope(789, ScopeRoleManage))
+
+	co, err := b.loadUser(456)
+	assert.NoError(t, err)
+	owner, err := b.loadUser(123)
+	assert.NoError(t, err)
+	_, err = b.changeUserRole(context.Background(), 456, owner, "user")
+	assert.EqualError(t, err, "the owner's role can't be changed")
+	regular, err := b.loadUser(789)
+	assert.NoError(t, err)
+	_, err = b.changeUserRole(context.Background(), co.TelegramID, regular, "admin")
+	assert.NoError(t, err)
+
+	send := func(text string) {
+		b.handleUpdate(context.Background(), nil, commandUpdate(456, 456, text))
+	}
+	send("/ban 123 permanent")
+	assert.Equal(t, "You can't ban yourself or the owner.", lastSent)
+	send("/transfer_owner 456")
+	assert.Contains(t, lastSent, "Only the owner can transfer ownership")
+}
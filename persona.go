@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// personaDefault is the /persona argument that returns a chat to default_persona.
+const personaDefault = "default"
+
+// personaScope is the scope that lets a role switch to the named persona.
+func personaScope(name string) string {
+	return ScopePersonaPrefix + name
+}
+
+// createPersonaScopes seeds a scope for each persona. Like the built-in scopes, only newly created
+// ones are granted to the default roles, so admins can restrict a persona with /role_revoke and
+// the change survives restarts.
+func createPersonaScopes(db *gorm.DB, personas map[string]Persona) error {
+	var created []string
+	for _, name := range slices.Sorted(maps.Keys(personas)) {
+		result := db.FirstOrCreate(&Scope{}, Scope{Name: personaScope(name)})
+		if result.Error != nil {
+			return fmt.Errorf("failed to create scope for persona %s: %w", name, result.Error)
+		}
+		if result.RowsAffected > 0 {
+			created = append(created, personaScope(name))
+		}
+	}
+	if len(created) == 0 {
+		return nil
+	}
+	for _, roleName := range defaultRoles {
+		if err := grantScopesToRoles(db, roleName, created); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chatPersona returns the persona a chat uses: its own choice, else default_persona. ok is false
+// when neither is set or configured any more, in which case the bot's own settings apply.
+func (b *Bot) chatPersona(chatID int64) (name string, persona Persona, ok bool) {
+	var choice ChatPersona
+	err := b.db.Where("bot_id = ? AND chat_id = ?", b.botID, chatID).First(&choice).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorLogger.Printf("Error loading the persona of chat %d: %v", chatID, err)
+	}
+	for _, name := range []string{choice.Persona, b.config.DefaultPersona} {
+		if persona, ok := b.config.Personas[name]; ok && name != "" {
+			return name, persona, true
+		}
+	}
+	return "", Persona{}, false
+}
+
+// systemPrompts returns the bot's system prompts with the persona's overrides applied.
+func (p Persona) systemPrompts(base map[string]string) map[string]string {
+	prompts := maps.Clone(base)
+	if prompts == nil {
+		prompts = make(map[string]string, len(p.SystemPrompts))
+	}
+	maps.Copy(prompts, p.SystemPrompts)
+	return prompts
+}
+
+// voiceID returns the ElevenLabs voice of a chat's persona, or the bot's voice.
+func (b *Bot) voiceID(chatID int64) string {
+	if _, persona, ok := b.chatPersona(chatID); ok && persona.ElevenLabsVoiceID != "" {
+		return persona.ElevenLabsVoiceID
+	}
+	return b.config.ElevenLabsVoiceID
+}
+
+// handlePersonaCommand handles /persona [name]: without a name it lists the personas the caller
+// may use, otherwise it switches the chat to that persona. "/persona default" undoes the choice.
+// In groups only group admins can switch.
+func (b *Bot) handlePersonaCommand(ctx context.Context, c *commandCall) {
+	userID, chatID := c.userID(), c.chatID()
+	if len(b.config.Personas) == 0 {
+		c.reply("This bot has no personas.")
+		return
+	}
+
+	if !c.has("name") {
+		current, _, _ := b.chatPersona(chatID)
+		var sb strings.Builder
+		sb.WriteString("🎭 Personas:")
+		for _, name := range slices.Sorted(maps.Keys(b.config.Personas)) {
+			if !b.hasScope(userID, personaScope(name)) && name != current {
+				continue
+			}
+			fmt.Fprintf(&sb, "\n- %s", name)
+			if desc := b.config.Personas[name].Description; desc != "" {
+				sb.WriteString(" — " + desc)
+			}
+			if name == current {
+				sb.WriteString(" (active)")
+			}
+		}
+		sb.WriteString("\n\nSend /persona <name> to switch, or /persona default to go back.")
+		c.reply(sb.String())
+		return
+	}
+
+	if isGroupChat(c.message.Chat) && !b.isGroupAdmin(ctx, chatID, userID) {
+		c.reply("Permission denied. Only group admins can change the persona of a group.")
+		return
+	}
+	name := strings.ToLower(c.str("name"))
+	if name == personaDefault {
+		if err := b.db.Where("bot_id = ? AND chat_id = ?", b.botID, chatID).Delete(&ChatPersona{}).Error; err != nil {
+			ErrorLogger.Printf("Error resetting the persona of chat %d: %v", chatID, err)
+			c.reply("Sorry, I couldn't change the persona.")
+			return
+		}
+		InfoLogger.Printf("[%s] User %d reset the persona of chat %d", b.config.ID, userID, chatID)
+		c.reply("✅ Back to the default persona.")
+		return
+	}
+	if _, ok := b.config.Personas[name]; !ok {
+		c.reply(fmt.Sprintf("❌ Unknown persona %q. Send /persona for the list.", name))
+		return
+	}
+	if !b.hasScope(userID, personaScope(name)) {
+		c.reply(fmt.Sprintf("Permission denied. Your role can't use the persona %s.", name))
+		return
+	}
+
+	err := b.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "bot_id"}, {Name: "chat_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"updated_at", "persona"}),
+	}).Create(&ChatPersona{BotID: b.botID, ChatID: chatID, Persona: name}).Error
+	if err != nil {
+		ErrorLogger.Printf("Error setting the persona of chat %d: %v", chatID, err)
+		c.reply("Sorry, I couldn't change the persona.")
+		return
+	}
+	InfoLogger.Printf("[%s] User %d switched chat %d to the persona %s", b.config.ID, userID, chatID, name)
+	c.reply(fmt.Sprintf("✅ Switched to the persona %s.", name))
+}
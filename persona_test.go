@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPersonas verifies switching personas per chat, what a persona changes in the completion
+// request, and that revoking a persona scope from a role survives restarts.
+func TestPersonas(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	var lastSent string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+	send := func(userID int64, text string) {
+		assert.True(t, b.dispatchCommand(context.Background(), commandUpdate(userID, userID, text).Message, ""))
+	}
+
+	temperature := float32(0.2)
+	b.config.SystemPrompts = map[string]string{"default": "You are helpful.", "avoid_sensitive": "Be careful."}
+	b.config.ElevenLabsVoiceID = "bot-voice"
+	b.config.Personas = map[string]Persona{
+		"pirate": {Description: "Talks like a pirate", SystemPrompts: map[string]string{"default": "You are a pirate."},
+			Model: "claude-pirate", Temperature: &temperature, ElevenLabsVoiceID: "pirate-voice"},
+		"butler": {Description: "Very formal"},
+	}
+	assert.NoError(t, createPersonaScopes(b.db, b.config.Personas))
+	_, err := b.getOrCreateUser(789, "regular", false)
+	assert.NoError(t, err)
+
+	// Without a choice the bot's own settings apply.
+	request := b.buildCompletionRequest(context.Background(), 789, 789, nil, false, false, false, "", "", "", false, "", 0)
+	assert.Contains(t, request.System, "You are helpful.")
+	assert.Equal(t, "claude-3-5-haiku-latest", request.Model)
+	assert.Nil(t, request.Temperature)
+
+	send(789, "/persona")
+	assert.Contains(t, lastSent, "- butler — Very formal")
+	assert.Contains(t, lastSent, "- pirate — Talks like a pirate")
+
+	send(789, "/persona pirate")
+	assert.Equal(t, "✅ Switched to the persona pirate.", lastSent)
+	request = b.buildCompletionRequest(context.Background(), 789, 789, nil, false, false, false, "", "", "", false, "", 0)
+	assert.Contains(t, request.System, "You are a pirate.")
+	assert.Contains(t, request.System, "Be careful.", "prompts the persona doesn't override are kept")
+	assert.Equal(t, "claude-pirate", request.Model)
+	assert.Equal(t, &temperature, request.Temperature)
+	assert.Equal(t, "pirate-voice", b.voiceID(789))
+	assert.Equal(t, "bot-voice", b.voiceID(456), "other chats keep the bot's settings")
+
+	send(789, "/persona")
+	assert.Contains(t, lastSent, "Talks like a pirate (active)")
+	send(789, "/persona parrot")
+	assert.Contains(t, lastSent, "Unknown persona")
+
+	// Admins restrict a persona by revoking its scope; a restart doesn't grant it again.
+	send(123, "/role_revoke user persona:butler")
+	assert.Contains(t, lastSent, "Revoked persona:butler")
+	assert.NoError(t, createPersonaScopes(b.db, b.config.Personas))
+	send(789, "/persona butler")
+	assert.Equal(t, "Permission denied. Your role can't use the persona butler.", lastSent)
+	send(789, "/persona")
+	assert.NotContains(t, lastSent, "butler")
+
+	send(789, "/persona default")
+	assert.Equal(t, "✅ Back to the default persona.", lastSent)
+	assert.Equal(t, "bot-voice", b.voiceID(789))
+
+	// default_persona applies to chats without a choice.
+	b.config.DefaultPersona = "pirate"
+	request = b.buildCompletionRequest(context.Background(), 789, 789, nil, false, false, false, "", "", "", false, "", 0)
+	assert.Equal(t, "claude-pirate", request.Model)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// promptData holds the variables system prompts can use as Go templates, e.g. {{.FirstName}} or
+// {{if .Premium}}…{{end}}. The README lists them; keep both in sync.
+type promptData struct {
+	Username      string // Telegram username, "unknown" when unset
+	FirstName     string // "unknown" when unset
+	LastName      string
+	Language      string // Telegram language code, "en" when unset
+	Premium       bool   // Telegram Premium subscriber
+	PremiumStatus string // "premium user" or "regular user"
+	TimeOfDay     string // "morning", "afternoon", "evening" or "night"
+	Date          string // Date of the message, e.g. "2026-10-17"
+	Time          string // Time of the message, e.g. "14:05"
+	Weekday       string // e.g. "Saturday"
+	Timezone      string // Name of the timezone the date and time are in
+	ChatType      string // "private" or "group"
+	Role          string // The user's role, e.g. "user" or "admin"
+	IsOwner       bool
+	BotName       string // The bot's Telegram display name
+	MessageCount  int64  // Messages the user has sent in this chat, including the current one
+	NewChat       bool   // Whether this is the first message of the chat
+	Persona       string // Active persona, empty when none
+	Vars          map[string]string
+}
+
+// legacyActions maps the {placeholder} syntax used before templates to template actions, so
+// existing prompts keep working.
+var legacyActions = map[string]string{
+	"{username}":       "{{.Username}}",
+	"{firstname}":      "{{.FirstName}}",
+	"{lastname}":       "{{.LastName}}",
+	"{language}":       "{{.Language}}",
+	"{premium_status}": "{{.PremiumStatus}}",
+	"{time_context}":   "{{.TimeOfDay}}",
+}
+
+// legacyPlaceholders rewrites legacyActions.
+var legacyPlaceholders = func() *strings.Replacer {
+	var pairs []string
+	for placeholder, action := range legacyActions {
+		pairs = append(pairs, placeholder, action)
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// legacyPattern matches single-brace placeholders such as {firstname}, so mistyped ones fail
+// validation instead of reaching the model as literal text.
+var legacyPattern = regexp.MustCompile(`\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+// promptVarPattern matches the prompt_vars names usable as {{.Vars.name}}.
+var promptVarPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parsePrompt parses a system prompt as a template. Unknown prompt_vars fail instead of rendering
+// as "<no value>".
+func parsePrompt(name, text string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Parse(legacyPlaceholders.Replace(text))
+}
+
+// validatePrompt checks that a system prompt parses and only uses known variables and
+// placeholders, including in branches a sample render wouldn't reach.
+func validatePrompt(name, text string, vars map[string]string) error {
+	for _, loc := range legacyPattern.FindAllStringIndex(text, -1) {
+		if loc[0] > 0 && text[loc[0]-1] == '{' || loc[1] < len(text) && text[loc[1]] == '}' {
+			continue // Part of a template action such as {{end}}
+		}
+		if placeholder := text[loc[0]:loc[1]]; legacyActions[placeholder] == "" {
+			return fmt.Errorf("unknown placeholder %s", placeholder)
+		}
+	}
+	tmpl, err := parsePrompt(name, text)
+	if err != nil {
+		return err
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		if err := checkPromptFields(t.Tree.Root, vars, true); err != nil {
+			return err
+		}
+	}
+	return tmpl.Execute(io.Discard, promptData{Vars: vars})
+}
+
+// checkPromptFields walks a template and reports variables promptData doesn't have. Fields are
+// only checked where dot is still promptData, i.e. outside range and with blocks, and through $.
+func checkPromptFields(node parse.Node, vars map[string]string, rootDot bool) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkPromptFields(child, vars, rootDot); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkPromptFields(n.Pipe, vars, rootDot)
+	case *parse.TemplateNode:
+		return checkPromptFields(n.Pipe, vars, rootDot)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkPromptFields(cmd, vars, rootDot); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := checkPromptFields(arg, vars, rootDot); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return checkBranch(&n.BranchNode, vars, rootDot, rootDot)
+	case *parse.RangeNode:
+		return checkBranch(&n.BranchNode, vars, rootDot, false)
+	case *parse.WithNode:
+		return checkBranch(&n.BranchNode, vars, rootDot, false)
+	case *parse.FieldNode:
+		if rootDot {
+			return checkPromptField(n.Ident, vars)
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			return checkPromptField(n.Ident[1:], vars)
+		}
+	}
+	return nil
+}
+
+// checkBranch checks an if, range or with node; bodyRootDot says whether dot is still promptData
+// in its body.
+func checkBranch(n *parse.BranchNode, vars map[string]string, rootDot, bodyRootDot bool) error {
+	if err := checkPromptFields(n.Pipe, vars, rootDot); err != nil {
+		return err
+	}
+	if err := checkPromptFields(n.List, vars, bodyRootDot); err != nil {
+		return err
+	}
+	return checkPromptFields(n.ElseList, vars, rootDot)
+}
+
+// checkPromptField checks a field chain such as .FirstName or .Vars.company.
+func checkPromptField(ident []string, vars map[string]string) error {
+	if _, ok := reflect.TypeFor[promptData]().FieldByName(ident[0]); !ok {
+		return fmt.Errorf("unknown variable .%s", ident[0])
+	}
+	if ident[0] == "Vars" && len(ident) > 1 {
+		if _, ok := vars[ident[1]]; !ok {
+			return fmt.Errorf("unknown variable .Vars.%s; define it in prompt_vars", ident[1])
+		}
+	}
+	return nil
+}
+
+// renderPrompt renders a system prompt. Prompts are validated when the config is loaded, so a
+// failure here is logged and the prompt is used as written.
+func renderPrompt(name, text string, data promptData) string {
+	if !strings.Contains(text, "{") {
+		return text
+	}
+	tmpl, err := parsePrompt(name, text)
+	if err != nil {
+		ErrorLogger.Printf("Error parsing system prompt %s: %v", name, err)
+		return text
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		ErrorLogger.Printf("Error rendering system prompt %s: %v", name, err)
+		return text
+	}
+	return sb.String()
+}
+
+// timeOfDay names the part of the day of t.
+func timeOfDay(t time.Time) string {
+	switch hour := t.Hour(); {
+	case hour >= 5 && hour < 12:
+		return "morning"
+	case hour >= 12 && hour < 18:
+		return "afternoon"
+	case hour >= 18 && hour < 22:
+		return "evening"
+	default:
+		return "night"
+	}
+}
+
+// location returns the timezone prompts use for dates and times: the configured one, or the
+// server's local time.
+func (b *Bot) location() *time.Location {
+	if b.config.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(b.config.Timezone)
+	if err != nil {
+		ErrorLogger.Printf("Error loading timezone %s: %v", b.config.Timezone, err)
+		return time.Local
+	}
+	return loc
+}
+
+// promptVariables gathers the template variables for a request from a user in a chat.
+func (b *Bot) promptVariables(ctx context.Context, chatID, userID int64, isNewChat, isOwner bool, persona, username, firstName, lastName string, isPremium bool, languageCode string, messageTime int) promptData {
+	data := promptData{
+		Username:      username,
+		FirstName:     firstName,
+		LastName:      lastName,
+		Language:      languageCode,
+		Premium:       isPremium,
+		PremiumStatus: "regular user",
+		ChatType:      "private",
+		Role:          defaultRoleName,
+		IsOwner:       isOwner,
+		BotName:       b.config.ID,
+		NewChat:       isNewChat,
+		Persona:       persona,
+		Vars:          b.config.PromptVars,
+	}
+	if data.Username == "" {
+		data.Username = "unknown"
+	}
+	if data.FirstName == "" {
+		data.FirstName = "unknown"
+	}
+	if data.Language == "" {
+		data.Language = "en"
+	}
+	if isPremium {
+		data.PremiumStatus = "premium user"
+	}
+	if isGroupChatID(chatID) {
+		data.ChatType = "group"
+	}
+
+	loc := b.location()
+	at := time.Unix(int64(messageTime), 0).In(loc)
+	data.TimeOfDay = timeOfDay(at)
+	data.Date, data.Time, data.Weekday = at.Format(time.DateOnly), at.Format("15:04"), at.Weekday().String()
+	data.Timezone = loc.String()
+
+	if user, err := b.loadUser(userID); err == nil {
+		data.Role = user.Role.Name
+		if user.IsOwner {
+			data.Role = "owner"
+		}
+	}
+	if me, err := b.botUser(ctx); err == nil && me.FirstName != "" {
+		data.BotName = me.FirstName
+	}
+	if err := b.db.Model(&Message{}).Where("bot_id = ? AND chat_id = ? AND user_id = ? AND is_user = ?", b.botID, chatID, userID, true).
+		Count(&data.MessageCount).Error; err != nil {
+		ErrorLogger.Printf("Error counting the messages of user %d: %v", userID, err)
+	}
+	return data
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidatePrompt verifies that typos fail validation, including in branches a sample render
+// doesn't reach, while legacy placeholders and custom variables pass.
+func TestValidatePrompt(t *testing.T) {
+	vars := map[string]string{"company": "Example Inc."}
+	tests := []struct {
+		name    string
+		prompt  string
+		wantErr string
+	}{
+		{name: "Plain text", prompt: "You are a helpful assistant."},
+		{name: "Legacy placeholders", prompt: "Talking to {username} ({firstname} {lastname}), a {premium_status}, this {time_context}."},
+		{name: "Conditionals and custom variables", prompt: "{{if .Premium}}Thank {{.FirstName}} for supporting {{.Vars.company}}.{{else}}Hi!{{end}}"},
+		{name: "Range changes dot", prompt: "{{range $k, $v := .Vars}}{{$k}}={{.}} {{$.Role}}{{end}}"},
+		{name: "Typo in an unvisited branch", prompt: "{{if .Premium}}{{.FirstNme}}{{end}}", wantErr: "unknown variable .FirstNme"},
+		{name: "Typo through $", prompt: "{{with .Vars}}{{$.Rle}}{{end}}", wantErr: "unknown variable .Rle"},
+		{name: "Mistyped placeholder", prompt: "Hi {firstnam}!", wantErr: "unknown placeholder {firstnam}"},
+		{name: "Braces that aren't placeholders", prompt: "Reply in JSON like {\"name\": \"x\"} or {1}."},
+		{name: "Unknown custom variable", prompt: "Work for {{.Vars.employer}}", wantErr: "unknown variable .Vars.employer"},
+		{name: "Syntax error", prompt: "{{if .Premium}}unterminated", wantErr: "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePrompt("default", tt.prompt, vars)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRenderPrompt verifies rendering of legacy placeholders and conditionals.
+func TestRenderPrompt(t *testing.T) {
+	data := promptData{Username: "testuser", FirstName: "Test", LastName: "User", Language: "de",
+		Premium: true, PremiumStatus: "premium user", TimeOfDay: "afternoon"}
+	assert.Equal(t, "testuser (Test User) speaks de, is a premium user; afternoon.",
+		renderPrompt("default", "{username} ({firstname} {lastname}) speaks {language}, is a {premium_status}; {time_context}.", data))
+	assert.Equal(t, "VIP", renderPrompt("default", "{{if .Premium}}VIP{{else}}regular{{end}}", data))
+
+	// Unvalidated prompts that fail are used as written.
+	assert.Equal(t, "Hi {{.Nope}}", renderPrompt("default", "Hi {{.Nope}}", data))
+}
+
+// TestPromptVariables verifies the variables a request's system prompt is rendered with.
+func TestPromptVariables(t *testing.T) {
+	b, _ := setupBotForTest(t, 123)
+	b.config.Timezone = "Asia/Tokyo"
+	b.config.PromptVars = map[string]string{"company": "Example Inc."}
+	b.config.SystemPrompts = map[string]string{
+		"default": "{{.BotName}} of {{.Vars.company}} talks to a {{.Role}} in a {{.ChatType}} chat ({{.MessageCount}} messages) " +
+			"on {{.Weekday}} {{.Date}} at {{.Time}} {{.Timezone}}, {{.TimeOfDay}}.{{if .IsOwner}} Owner!{{end}}",
+	}
+	_, err := b.getOrCreateUser(789, "regular", false)
+	assert.NoError(t, err)
+	for range 2 {
+		assert.NoError(t, b.db.Create(&Message{BotID: b.botID, ChatID: 789, UserID: 789, IsUser: true, Text: "hi"}).Error)
+	}
+
+	at := time.Date(2026, 10, 16, 23, 30, 0, 0, time.UTC) // Saturday morning in Tokyo
+	request := b.buildCompletionRequest(context.Background(), 789, 789, nil, false, false, false, "", "", "", false, "", int(at.Unix()))
+	assert.Contains(t, request.System,
+		"test_bot of Example Inc. talks to a user in a private chat (2 messages) on Saturday 2026-10-17 at 08:30 Asia/Tokyo, morning.")
+	assert.NotContains(t, request.System, "Owner!")
+
+	request = b.buildCompletionRequest(context.Background(), -100, 123, nil, false, true, false, "", "", "", false, "", int(at.Unix()))
+	assert.Contains(t, request.System, "talks to a owner in a group chat (0 messages)")
+	assert.Contains(t, request.System, "Owner!")
+}
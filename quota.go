@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-telegram/bot"
+)
+
+// Quota caps model usage per calendar day and month. Zero fields are unlimited.
+type Quota struct {
+	DailyTokens   int64   `json:"daily_tokens"`
+	MonthlyTokens int64   `json:"monthly_tokens"`
+	DailyCost     float64 `json:"daily_cost"`   // USD, estimated with the price table
+	MonthlyCost   float64 `json:"monthly_cost"` // USD, estimated with the price table
+}
+
+// QuotaConfig holds the spending quotas of a bot.
+type QuotaConfig struct {
+	User           Quota            `json:"user"`            // Applies to every user without a role quota
+	Roles          map[string]Quota `json:"roles"`           // Replaces the user quota for members of a role, e.g. "admin"
+	Bot            Quota            `json:"bot"`             // Shared by all users of the bot
+	AlertThreshold float64          `json:"alert_threshold"` // Fraction of a bot quota (e.g. 0.8) at which the owner is notified
+}
+
+// validate reports negative limits.
+func (q Quota) validate() error {
+	if q.DailyTokens < 0 || q.MonthlyTokens < 0 || q.DailyCost < 0 || q.MonthlyCost < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+// quotaPeriod is the limit of one calendar period of a quota.
+type quotaPeriod struct {
+	name   string // "daily" or "monthly"
+	since  time.Time
+	resets string
+	tokens int64
+	cost   float64
+}
+
+// periods returns the periods of q that have a limit.
+func (q Quota) periods(now time.Time) []quotaPeriod {
+	var periods []quotaPeriod
+	if q.DailyTokens > 0 || q.DailyCost > 0 {
+		periods = append(periods, quotaPeriod{"daily", startOfDay(now), "tomorrow", q.DailyTokens, q.DailyCost})
+	}
+	if q.MonthlyTokens > 0 || q.MonthlyCost > 0 {
+		periods = append(periods, quotaPeriod{"monthly", startOfMonth(now), "next month", q.MonthlyTokens, q.MonthlyCost})
+	}
+	return periods
+}
+
+// reached reports whether totals use at least fraction of a limit of the period.
+func (p quotaPeriod) reached(totals UsageTotals, fraction float64) bool {
+	if p.tokens > 0 && float64(totals.Tokens()) >= float64(p.tokens)*fraction {
+		return true
+	}
+	return p.cost > 0 && totals.Cost >= p.cost*fraction
+}
+
+// userQuota returns the quota of a user: their role's quota if configured, otherwise the user
+// quota. Owners are not limited per user.
+func (b *Bot) userQuota(userID int64) Quota {
+	var user User
+	if err := b.db.Preload("Role").
+		Where("telegram_id = ? AND bot_id = ?", userID, b.botID).
+		First(&user).Error; err != nil {
+		return b.config.Quotas.User
+	}
+	if user.IsOwner {
+		return Quota{}
+	}
+	if quota, ok := b.config.Quotas.Roles[user.Role.Name]; ok {
+		return quota
+	}
+	return b.config.Quotas.User
+}
+
+// checkQuotas reports whether userID may make another model call under the user and bot quotas.
+// When not, it returns the message to send to the user. Errors reading usage are logged and the
+// call is allowed, so a database hiccup never locks everyone out.
+func (b *Bot) checkQuotas(ctx context.Context, userID int64) (string, bool) {
+	now := b.clock.Now()
+
+	for _, period := range b.userQuota(userID).periods(now) {
+		totals, err := b.getUsageTotals(userID, period.since)
+		if err != nil {
+			ErrorLogger.Printf("Error reading usage of user %d: %v", userID, err)
+			return "", true
+		}
+		if period.reached(totals, 1) {
+			InfoLogger.Printf("[%s] User %d reached their %s quota", b.config.ID, userID, period.name)
+			return fmt.Sprintf("You have used up your %s usage allowance. Please try again %s.", period.name, period.resets), false
+		}
+	}
+
+	threshold := b.config.Quotas.AlertThreshold
+	for _, period := range b.config.Quotas.Bot.periods(now) {
+		totals, err := b.getUsageTotals(0, period.since)
+		if err != nil {
+			ErrorLogger.Printf("Error reading usage of the bot: %v", err)
+			return "", true
+		}
+		if period.reached(totals, 1) {
+			b.notifyOwnerOfBudget(ctx, period, totals, true)
+			return fmt.Sprintf("This bot has used up its %s budget. Please try again %s.", period.name, period.resets), false
+		}
+		if threshold > 0 && period.reached(totals, threshold) {
+			b.notifyOwnerOfBudget(ctx, period, totals, false)
+		}
+	}
+	return "", true
+}
+
+// notifyOwnerOfBudget tells the owner that a bot-wide quota period is nearly or fully used, once
+// per period and level.
+func (b *Bot) notifyOwnerOfBudget(ctx context.Context, period quotaPeriod, totals UsageTotals, exhausted bool) {
+	level := "alert"
+	if exhausted {
+		level = "exhausted"
+	}
+	key := fmt.Sprintf("%s:%s:%s", period.name, period.since.Format(time.DateOnly), level)
+
+	b.quotaAlertsMu.Lock()
+	if b.quotaAlerts[key] {
+		b.quotaAlertsMu.Unlock()
+		return
+	}
+	b.quotaAlerts[key] = true
+	b.quotaAlertsMu.Unlock()
+
+	text := fmt.Sprintf("⚠️ Bot %s has used %d tokens ($%.4f) of its %s budget", b.config.ID, totals.Tokens(), totals.Cost, period.name)
+	if period.tokens > 0 {
+		text += fmt.Sprintf(" (limit: %d tokens)", period.tokens)
+	}
+	if period.cost > 0 {
+		text += fmt.Sprintf(" (limit: $%.2f)", period.cost)
+	}
+	if exhausted {
+		text += ". The budget is exhausted; users are refused until it resets."
+	} else {
+		text += "."
+	}
+
+	// Sent directly rather than through sendResponse: alerts are not part of the owner's conversation.
+	if _, err := b.tgBot.SendMessage(ctx, &bot.SendMessageParams{ChatID: b.ownerTelegramID(), Text: text}); err != nil {
+		ErrorLogger.Printf("[%s] Error notifying owner about the %s budget: %v", b.config.ID, period.name, err)
+	}
+}
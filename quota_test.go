@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// storeUsage stores an assistant reply carrying usage billed to userID.
+func storeUsage(t *testing.T, b *Bot, userID int64, tokens int, cost float64) {
+	t.Helper()
+	msg := b.createMessage(userID, 0, "", "assistant", "reply", false)
+	msg.BilledUserID = userID
+	msg.InputTokens = tokens
+	msg.Cost = cost
+	assert.NoError(t, b.storeMessage(&msg))
+}
+
+// TestCheckQuotas verifies user, role and bot quotas, and that the owner is alerted once.
+func TestCheckQuotas(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	var sent []*bot.SendMessageParams
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		sent = append(sent, params)
+		return &models.Message{}, nil
+	}
+	_, err := b.getOrCreateUser(789, "regular", false)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	// User quota.
+	b.config.Quotas.User = Quota{DailyTokens: 1000}
+	_, ok := b.checkQuotas(ctx, 789)
+	assert.True(t, ok)
+	storeUsage(t, b, 789, 1000, 0.01)
+	refusal, ok := b.checkQuotas(ctx, 789)
+	assert.False(t, ok)
+	assert.Equal(t, "You have used up your daily usage allowance. Please try again tomorrow.", refusal)
+
+	// A role quota replaces the user quota; the owner is not limited per user.
+	b.config.Quotas.Roles = map[string]Quota{"user": {MonthlyCost: 1}}
+	_, ok = b.checkQuotas(ctx, 789)
+	assert.True(t, ok)
+	b.config.Quotas.User = Quota{DailyTokens: 1}
+	storeUsage(t, b, 123, 10, 0)
+	_, ok = b.checkQuotas(ctx, 123)
+	assert.True(t, ok)
+
+	// Bot quota: alert at the threshold, refuse everyone once exhausted, notify once per level.
+	b.config.Quotas.Bot = Quota{MonthlyTokens: 2000}
+	b.config.Quotas.AlertThreshold = 0.5
+	_, ok = b.checkQuotas(ctx, 789)
+	assert.True(t, ok)
+	_, ok = b.checkQuotas(ctx, 789)
+	assert.True(t, ok)
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, int64(123), sent[0].ChatID)
+		assert.Contains(t, sent[0].Text, "of its monthly budget (limit: 2000 tokens).")
+	}
+
+	storeUsage(t, b, 555, 990, 0)
+	refusal, ok = b.checkQuotas(ctx, 123)
+	assert.False(t, ok)
+	assert.Equal(t, "This bot has used up its monthly budget. Please try again next month.", refusal)
+	_, _ = b.checkQuotas(ctx, 789)
+	if assert.Len(t, sent, 2) {
+		assert.Contains(t, sent[1].Text, "The budget is exhausted")
+	}
+}
+
+// TestHandleUpdate_QuotaExceeded verifies that the model is not called once the quota is used up.
+func TestHandleUpdate_QuotaExceeded(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, mockTgClient := setupBotForTest(t, 123)
+	b.config.Quotas.User = Quota{DailyCost: 0.01}
+	var lastSent string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+	var calls int
+	b.llm = &MockLLMProvider{CompleteFunc: func(context.Context, CompletionRequest) (CompletionResponse, error) {
+		calls++
+		return CompletionResponse{Text: "reply"}, nil
+	}}
+	storeUsage(t, b, 789, 100, 0.02)
+
+	b.handleUpdate(context.Background(), nil, &models.Update{Message: &models.Message{
+		Chat: models.Chat{ID: 789},
+		From: &models.User{ID: 789, Username: "regular"},
+		Text: "hello",
+	}})
+
+	assert.Zero(t, calls)
+	assert.Contains(t, lastSent, "daily usage allowance")
+}
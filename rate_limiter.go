@@ -1,52 +1,225 @@
 package main
 
 import (
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"golang.org/x/time/rate"
+	"gorm.io/gorm/clause"
 )
 
+const (
+	// limiterStaleAfter is how long an unbanned user's limiter is kept after their last message.
+	// By then both buckets would be reset anyway, so a fresh limiter is equivalent.
+	limiterStaleAfter = 24 * time.Hour
+	// limiterGCInterval is how often stale limiters are swept from memory and the database.
+	limiterGCInterval = time.Hour
+	// Defaults of the escalation policy when rate_limit_policy leaves them unset.
+	defaultViolationWindow = time.Hour
+	defaultFreeViolations  = 3
+)
+
+// defaultBanLadder is the temp ban escalation when none is configured; temp_ban_duration is the
+// last step.
+var defaultBanLadder = []string{"10m", "1h"}
+
+// RateLimitPolicy controls how repeated rate limit violations escalate. A violation is a message
+// sent while out of budget; the first FreeViolations within ViolationWindow are only told how long
+// to wait, each further one is temporarily banned for the next step of BanLadder (the last step
+// repeats).
+type RateLimitPolicy struct {
+	ViolationWindow string   `json:"violation_window"` // e.g. "1h"; defaults to 1h
+	FreeViolations  *int     `json:"free_violations"`  // Defaults to 3
+	BanLadder       []string `json:"ban_ladder"`       // e.g. ["10m", "1h", "24h"]; defaults to 10m, 1h, temp_ban_duration
+}
+
+// validate reports unparsable durations and negative counts.
+func (p RateLimitPolicy) validate() error {
+	if p.ViolationWindow != "" {
+		if d, err := time.ParseDuration(p.ViolationWindow); err != nil || d <= 0 {
+			return fmt.Errorf("invalid 'violation_window' %q", p.ViolationWindow)
+		}
+	}
+	if p.FreeViolations != nil && *p.FreeViolations < 0 {
+		return fmt.Errorf("'free_violations' must not be negative")
+	}
+	for _, step := range p.BanLadder {
+		if d, err := time.ParseDuration(step); err != nil || d <= 0 {
+			return fmt.Errorf("invalid 'ban_ladder' step %q", step)
+		}
+	}
+	return nil
+}
+
+// violationWindow returns how long violations are remembered.
+func (b *Bot) violationWindow() time.Duration {
+	if d, err := time.ParseDuration(b.config.RateLimitPolicy.ViolationWindow); err == nil && d > 0 {
+		return d
+	}
+	return defaultViolationWindow
+}
+
+// freeViolations returns how many violations in the window go without a ban.
+func (b *Bot) freeViolations() int {
+	if free := b.config.RateLimitPolicy.FreeViolations; free != nil {
+		return *free
+	}
+	return defaultFreeViolations
+}
+
+// banDuration returns the temp ban for the given escalation step (0 for the first ban).
+func (b *Bot) banDuration(step int) time.Duration {
+	ladder := b.config.RateLimitPolicy.BanLadder
+	if len(ladder) == 0 {
+		ladder = append(slices.Clone(defaultBanLadder), b.config.TempBanDuration)
+	}
+	step = min(step, len(ladder)-1)
+	banDuration, err := time.ParseDuration(ladder[step])
+	if err != nil {
+		// If parsing fails, default to a 24-hour ban
+		banDuration = 24 * time.Hour
+	}
+	return banDuration
+}
+
+// formatWait renders a wait time rounded up to whole seconds below a minute and to whole minutes
+// above, e.g. "45s" or "1h5m".
+func formatWait(d time.Duration) string {
+	unit := time.Minute
+	if d < time.Minute {
+		unit = time.Second
+	}
+	d = max((d + unit - 1).Truncate(unit), unit)
+	return strings.TrimSuffix(d.String(), "0s")
+}
+
+// rateLimitDecision is the outcome of a rate limit check.
+type rateLimitDecision struct {
+	allowed    bool
+	banned     bool          // Denied because of a temporary ban
+	retryAfter time.Duration // How long until the next message is allowed
+}
+
+// RateLimitTier overrides the bot's message limits for a role or user. Zero fields keep the
+// bot-wide messages_per_hour / messages_per_day.
+type RateLimitTier struct {
+	MessagesPerHour int `json:"messages_per_hour"`
+	MessagesPerDay  int `json:"messages_per_day"`
+}
+
 type userLimiter struct {
+	tier            RateLimitTier
 	hourlyLimiter   *rate.Limiter
 	dailyLimiter    *rate.Limiter
 	lastHourlyReset time.Time
 	lastDailyReset  time.Time
 	banUntil        time.Time
+	violations      int       // Violations since firstViolation
+	firstViolation  time.Time // Start of the current violation window
+	lastSeen        time.Time
 	clock           Clock
 }
 
+func newHourlyLimiter(perHour int) *rate.Limiter {
+	return rate.NewLimiter(rate.Every(time.Hour/time.Duration(perHour)), perHour)
+}
+
+func newDailyLimiter(perDay int) *rate.Limiter {
+	return rate.NewLimiter(rate.Every(24*time.Hour/time.Duration(perDay)), perDay)
+}
+
+// defaultRateLimitTier returns the bot-wide message limits.
+func (b *Bot) defaultRateLimitTier() RateLimitTier {
+	return RateLimitTier{MessagesPerHour: b.config.MessagePerHour, MessagesPerDay: b.config.MessagePerDay}
+}
+
+// rateLimitTier resolves the message limits of a user: their per-user override, else their role's
+// tier, else the bot defaults. exempt is true for holders of ratelimit:bypass, and for the owner.
+func (b *Bot) rateLimitTier(userID int64) (tier RateLimitTier, exempt bool) {
+	var override RateLimitTier
+	if b.db != nil {
+		var user User
+		if err := b.db.Preload("Role.Scopes").
+			Where("telegram_id = ? AND bot_id = ?", userID, b.botID).
+			First(&user).Error; err == nil {
+			if b.actsAsOwner(user) || roleHasScope(user.Role, ScopeRateLimitBypass) {
+				return RateLimitTier{}, true
+			}
+			override = b.config.RateLimits[user.Role.Name]
+		}
+	}
+	if userOverride, ok := b.config.UserRateLimits[userID]; ok {
+		override = userOverride
+	}
+
+	tier = b.defaultRateLimitTier()
+	if override.MessagesPerHour > 0 {
+		tier.MessagesPerHour = override.MessagesPerHour
+	}
+	if override.MessagesPerDay > 0 {
+		tier.MessagesPerDay = override.MessagesPerDay
+	}
+	return tier, false
+}
+
 func (b *Bot) checkRateLimits(userID int64) bool {
+	return b.rateLimit(userID).allowed
+}
+
+// rateLimit consumes one message from the user's buckets, or reports how long they must wait.
+// Repeated violations escalate to temporary bans per the rate limit policy.
+func (b *Bot) rateLimit(userID int64) rateLimitDecision {
+	tier, exempt := b.rateLimitTier(userID)
+	if exempt {
+		return rateLimitDecision{allowed: true}
+	}
+
 	b.userLimitersMu.Lock()
 	defer b.userLimitersMu.Unlock()
 
+	if now := b.clock.Now(); now.Sub(b.lastLimiterGC) >= limiterGCInterval {
+		b.gcUserLimiters(now)
+	}
+
 	limiter, exists := b.userLimiters[userID]
-	if !exists {
-		limiter = &userLimiter{
-			hourlyLimiter:   rate.NewLimiter(rate.Every(time.Hour/time.Duration(b.config.MessagePerHour)), b.config.MessagePerHour),
-			dailyLimiter:    rate.NewLimiter(rate.Every(24*time.Hour/time.Duration(b.config.MessagePerDay)), b.config.MessagePerDay),
+	if !exists || limiter.tier != tier {
+		// A new user, or one whose tier changed (e.g. promoted): start with full buckets
+		// for the new limits, but keep a running ban.
+		fresh := &userLimiter{
+			tier:            tier,
+			hourlyLimiter:   newHourlyLimiter(tier.MessagesPerHour),
+			dailyLimiter:    newDailyLimiter(tier.MessagesPerDay),
 			lastHourlyReset: b.clock.Now(),
 			lastDailyReset:  b.clock.Now(),
 			clock:           b.clock,
 		}
+		if exists {
+			fresh.banUntil = limiter.banUntil
+		}
+		limiter = fresh
 		b.userLimiters[userID] = limiter
 	}
 
 	now := limiter.clock.Now()
+	limiter.lastSeen = now
+	defer b.saveUserLimiter(userID, limiter, now)
 
 	// Check if the user is currently banned
 	if now.Before(limiter.banUntil) {
-		return false
+		return rateLimitDecision{banned: true, retryAfter: limiter.banUntil.Sub(now)}
 	}
 
 	// Reset hourly limiter if an hour has passed since the last reset
 	if now.Sub(limiter.lastHourlyReset) >= time.Hour {
-		limiter.hourlyLimiter = rate.NewLimiter(rate.Every(time.Hour/time.Duration(b.config.MessagePerHour)), b.config.MessagePerHour)
+		limiter.hourlyLimiter = newHourlyLimiter(limiter.tier.MessagesPerHour)
 		limiter.lastHourlyReset = now
 	}
 
 	// Reset daily limiter if 24 hours have passed since the last reset
 	if now.Sub(limiter.lastDailyReset) >= 24*time.Hour {
-		limiter.dailyLimiter = rate.NewLimiter(rate.Every(24*time.Hour/time.Duration(b.config.MessagePerDay)), b.config.MessagePerDay)
+		limiter.dailyLimiter = newDailyLimiter(limiter.tier.MessagesPerDay)
 		limiter.lastDailyReset = now
 	}
 
@@ -55,17 +228,128 @@ func (b *Bot) checkRateLimits(userID int64) bool {
 	// This prevents consuming a token from one limiter when the other rejects.
 	dailyRes := limiter.dailyLimiter.ReserveN(now, 1)
 	hourlyRes := limiter.hourlyLimiter.ReserveN(now, 1)
-	if dailyRes.DelayFrom(now) > 0 || hourlyRes.DelayFrom(now) > 0 {
+	dailyDelay, hourlyDelay := dailyRes.DelayFrom(now), hourlyRes.DelayFrom(now)
+	if dailyDelay > 0 || hourlyDelay > 0 {
 		dailyRes.CancelAt(now)
 		hourlyRes.CancelAt(now)
-		banDuration, err := time.ParseDuration(b.config.TempBanDuration)
-		if err != nil {
-			// If parsing fails, default to a 24-hour ban
-			banDuration = 24 * time.Hour
+
+		if now.Sub(limiter.firstViolation) >= b.violationWindow() {
+			limiter.violations = 0
+			limiter.firstViolation = now
+		}
+		limiter.violations++
+		if step := limiter.violations - b.freeViolations() - 1; step >= 0 {
+			banDuration := b.banDuration(step)
+			limiter.banUntil = now.Add(banDuration)
+			InfoLogger.Printf("[%s] User %d temporarily banned for %v after %d rate limit violations", b.config.ID, userID, banDuration, limiter.violations)
+			return rateLimitDecision{banned: true, retryAfter: banDuration}
 		}
-		limiter.banUntil = now.Add(banDuration)
-		return false
+
+		// A bucket is refilled completely at its next reset, which may come before the next token.
+		retryAfter := max(
+			min(dailyDelay, limiter.lastDailyReset.Add(24*time.Hour).Sub(now)),
+			min(hourlyDelay, limiter.lastHourlyReset.Add(time.Hour).Sub(now)),
+		)
+		return rateLimitDecision{retryAfter: retryAfter}
+	}
+
+	return rateLimitDecision{allowed: true}
+}
+
+// saveUserLimiter persists the state of a user's limiter as of now. The caller must hold
+// userLimitersMu. Bots without a database (unit tests) keep the state in memory only.
+func (b *Bot) saveUserLimiter(userID int64, limiter *userLimiter, now time.Time) {
+	if b.db == nil {
+		return
+	}
+	state := RateLimitState{
+		BotID:           b.botID,
+		UserID:          userID,
+		HourlyLimit:     limiter.tier.MessagesPerHour,
+		DailyLimit:      limiter.tier.MessagesPerDay,
+		HourlyTokens:    limiter.hourlyLimiter.TokensAt(now),
+		DailyTokens:     limiter.dailyLimiter.TokensAt(now),
+		LastHourlyReset: limiter.lastHourlyReset,
+		LastDailyReset:  limiter.lastDailyReset,
+		BanUntil:        limiter.banUntil,
+		Violations:      limiter.violations,
+		FirstViolation:  limiter.firstViolation,
+		LastSeen:        limiter.lastSeen,
 	}
+	if err := b.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "bot_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"updated_at", "hourly_limit", "daily_limit", "hourly_tokens", "daily_tokens", "last_hourly_reset", "last_daily_reset", "ban_until",
+			"violations", "first_violation", "last_seen",
+		}),
+	}).Create(&state).Error; err != nil {
+		ErrorLogger.Printf("[%s] Error saving rate limit state of user %d: %v", b.config.ID, userID, err)
+	}
+}
 
-	return true
+// loadUserLimiters restores the limiters persisted by saveUserLimiter, skipping stale ones.
+// Buckets resume at their saved level and refill for the time the bot was down.
+func (b *Bot) loadUserLimiters() error {
+	now := b.clock.Now()
+	var states []RateLimitState
+	if err := b.db.Where("bot_id = ? AND (last_seen >= ? OR ban_until > ?)", b.botID, now.Add(-limiterStaleAfter), now).
+		Find(&states).Error; err != nil {
+		return err
+	}
+
+	b.userLimitersMu.Lock()
+	defer b.userLimitersMu.Unlock()
+	for _, state := range states {
+		tier := RateLimitTier{MessagesPerHour: state.HourlyLimit, MessagesPerDay: state.DailyLimit}
+		if tier.MessagesPerHour <= 0 || tier.MessagesPerDay <= 0 {
+			tier = b.defaultRateLimitTier()
+		}
+		b.userLimiters[state.UserID] = &userLimiter{
+			tier:            tier,
+			hourlyLimiter:   restoreLimiter(newHourlyLimiter(tier.MessagesPerHour), state.HourlyTokens, state.LastSeen),
+			dailyLimiter:    restoreLimiter(newDailyLimiter(tier.MessagesPerDay), state.DailyTokens, state.LastSeen),
+			lastHourlyReset: state.LastHourlyReset,
+			lastDailyReset:  state.LastDailyReset,
+			banUntil:        state.BanUntil,
+			violations:      state.Violations,
+			firstViolation:  state.FirstViolation,
+			lastSeen:        state.LastSeen,
+			clock:           b.clock,
+		}
+	}
+	if len(states) > 0 {
+		InfoLogger.Printf("[%s] Restored rate limit state of %d users", b.config.ID, len(states))
+	}
+	return nil
+}
+
+// restoreLimiter drains a full limiter down to the whole tokens it had left at time at.
+func restoreLimiter(limiter *rate.Limiter, tokens float64, at time.Time) *rate.Limiter {
+	if used := limiter.Burst() - int(tokens); used > 0 {
+		limiter.ReserveN(at, used)
+	}
+	return limiter
+}
+
+// gcUserLimiters drops the limiters of users who are not banned and have been quiet for
+// limiterStaleAfter, in memory and in the database. The caller must hold userLimitersMu.
+func (b *Bot) gcUserLimiters(now time.Time) {
+	b.lastLimiterGC = now
+	cutoff := now.Add(-limiterStaleAfter)
+	for userID, limiter := range b.userLimiters {
+		if limiter.lastSeen.Before(cutoff) && !now.Before(limiter.banUntil) {
+			delete(b.userLimiters, userID)
+		}
+	}
+	if b.db == nil {
+		return
+	}
+	if err := b.db.Where("bot_id = ? AND last_seen < ? AND ban_until <= ?", b.botID, cutoff, now).
+		Delete(&RateLimitState{}).Error; err != nil {
+		ErrorLogger.Printf("[%s] Error deleting stale rate limit state: %v", b.config.ID, err)
+	}
+	if err := b.db.Where("bot_id = ? AND until IS NOT NULL AND until <= ?", b.botID, now).
+		Delete(&Ban{}).Error; err != nil {
+		ErrorLogger.Printf("[%s] Error deleting expired bans: %v", b.config.ID, err)
+	}
 }
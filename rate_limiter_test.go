@@ -83,3 +83,177 @@ func TestCheckRateLimits(t *testing.T) {
 // To ensure thread safety and avoid race conditions during testing,
 // you can run the tests with the `-race` flag:
 // go test -race -v
+
+// TestRateLimitState_SurvivesRestart verifies that bucket levels and temporary bans are restored
+// by NewBot and that stale state is garbage-collected.
+func TestRateLimitState_SurvivesRestart(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, mockTgClient := setupBotForTest(t, 123)
+	noFreeViolations := 0
+	b.config.RateLimitPolicy.FreeViolations = &noFreeViolations
+	clock := b.clock.(*MockClock)
+	restart := func() *Bot {
+		restarted, err := NewBot(b.db, b.config, clock, mockTgClient)
+		if err != nil {
+			t.Fatalf("Failed to restart bot: %v", err)
+		}
+		return restarted
+	}
+
+	// User 1 uses 3 of 5 hourly messages; user 2 exceeds the limit and is banned for an hour.
+	for i := 0; i < 3; i++ {
+		b.checkRateLimits(1)
+	}
+	for i := 0; i < 6; i++ {
+		b.checkRateLimits(2)
+	}
+
+	b = restart()
+	if !b.checkRateLimits(1) || !b.checkRateLimits(1) {
+		t.Errorf("Expected the remaining hourly messages to be allowed after restart")
+	}
+	if b.checkRateLimits(1) {
+		t.Errorf("Expected the hourly limit to carry over the restart")
+	}
+	if decision := b.rateLimit(2); decision.allowed || !decision.banned {
+		t.Errorf("Expected the temporary ban to carry over the restart, got %+v", decision)
+	}
+
+	// Once everyone has been quiet and unbanned for a day, state is dropped everywhere.
+	clock.Advance(limiterStaleAfter + 2*time.Hour)
+	b.checkRateLimits(3)
+	if _, exists := b.userLimiters[1]; exists {
+		t.Errorf("Expected the stale limiter to be removed from memory")
+	}
+	var count int64
+	b.db.Model(&RateLimitState{}).Count(&count)
+	if count != 1 {
+		t.Errorf("Expected only the active user's state to remain, got %d rows", count)
+	}
+}
+
+// TestCheckRateLimits_Tiers verifies role tiers, per-user overrides and exemptions.
+func TestCheckRateLimits_Tiers(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, _ := setupBotForTest(t, 123)
+	b.config.RateLimits = map[string]RateLimitTier{"admin": {MessagesPerHour: 8}}
+	b.config.UserRateLimits = map[int64]RateLimitTier{790: {MessagesPerHour: 2}}
+
+	adminRole, err := b.getRoleByName("admin")
+	if err != nil {
+		t.Fatalf("Failed to get admin role: %v", err)
+	}
+	userRole, err := b.getRoleByName("user")
+	if err != nil {
+		t.Fatalf("Failed to get user role: %v", err)
+	}
+	for _, u := range []User{
+		{BotID: b.botID, TelegramID: 456, RoleID: adminRole.ID},
+		{BotID: b.botID, TelegramID: 789, RoleID: userRole.ID},
+		{BotID: b.botID, TelegramID: 790, RoleID: userRole.ID},
+	} {
+		if err := b.db.Create(&u).Error; err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	allowed := func(userID int64, attempts int) int {
+		n := 0
+		for i := 0; i < attempts; i++ {
+			if b.checkRateLimits(userID) {
+				n++
+			}
+		}
+		return n
+	}
+
+	if n := allowed(789, 10); n != 5 {
+		t.Errorf("Expected the default tier to allow 5 messages, got %d", n)
+	}
+	if n := allowed(456, 10); n != 8 {
+		t.Errorf("Expected the admin tier to allow 8 messages, got %d", n)
+	}
+	if n := allowed(790, 10); n != 2 {
+		t.Errorf("Expected the per-user override to allow 2 messages, got %d", n)
+	}
+	if n := allowed(123, 20); n != 20 {
+		t.Errorf("Expected the owner to be exempt, got %d", n)
+	}
+
+	// Granting ratelimit:bypass to a role exempts its members, even while temporarily banned.
+	var bypass Scope
+	if err := b.db.Where("name = ?", ScopeRateLimitBypass).First(&bypass).Error; err != nil {
+		t.Fatalf("Failed to find bypass scope: %v", err)
+	}
+	if err := b.db.Model(&adminRole).Association("Scopes").Append(&bypass); err != nil {
+		t.Fatalf("Failed to grant bypass scope: %v", err)
+	}
+	if n := allowed(456, 20); n != 20 {
+		t.Errorf("Expected ratelimit:bypass to exempt the admin, got %d", n)
+	}
+}
+
+// TestRateLimit_Escalation verifies that violations first report the wait time and only escalate
+// to increasingly long temp bans when repeated within the violation window.
+func TestRateLimit_Escalation(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	mockClock := &MockClock{currentTime: time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)}
+	free := 1
+	b := &Bot{
+		config: BotConfig{
+			MessagePerHour:  2,
+			MessagePerDay:   100,
+			TempBanDuration: "24h",
+			RateLimitPolicy: RateLimitPolicy{ViolationWindow: "1h", FreeViolations: &free, BanLadder: []string{"5m", "30m"}},
+		},
+		userLimiters: make(map[int64]*userLimiter),
+		clock:        mockClock,
+	}
+	userID := int64(12345)
+
+	b.rateLimit(userID)
+	b.rateLimit(userID)
+
+	// First violation: not banned, told to wait for the next hourly token (30 minutes).
+	decision := b.rateLimit(userID)
+	if decision.allowed || decision.banned || decision.retryAfter != 30*time.Minute {
+		t.Errorf("Expected a 30m wait without a ban, got %+v", decision)
+	}
+
+	// Second violation within the window: first ladder step.
+	decision = b.rateLimit(userID)
+	if !decision.banned || decision.retryAfter != 5*time.Minute {
+		t.Errorf("Expected a 5m ban, got %+v", decision)
+	}
+
+	// Still out of budget after the ban: the next step, and the last step repeats.
+	mockClock.Advance(5 * time.Minute)
+	if decision = b.rateLimit(userID); decision.retryAfter != 30*time.Minute {
+		t.Errorf("Expected a 30m ban, got %+v", decision)
+	}
+	mockClock.Advance(30 * time.Minute)
+	if decision = b.rateLimit(userID); !decision.allowed {
+		t.Errorf("Expected a message to be allowed after the ban, got %+v", decision)
+	}
+
+	// Violations outside the window start over without a ban.
+	mockClock.Advance(2 * time.Hour)
+	b.rateLimit(userID)
+	b.rateLimit(userID)
+	if decision = b.rateLimit(userID); decision.banned {
+		t.Errorf("Expected old violations to be forgotten, got %+v", decision)
+	}
+}
+
+func TestFormatWait(t *testing.T) {
+	tests := map[time.Duration]string{
+		200 * time.Millisecond:                  "1s",
+		45 * time.Second:                        "45s",
+		44*time.Second + 100*time.Millisecond:   "45s",
+		61 * time.Second:                        "2m",
+		10 * time.Minute:                        "10m",
+		time.Hour + 4*time.Minute + time.Second: "1h5m",
+	}
+	for d, want := range tests {
+		if got := formatWait(d); got != want {
+			t.Errorf("formatWait(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"gorm.io/gorm"
+)
+
+// defaultRoleName is the role of users who were never promoted, and the role /demote assigns.
+const defaultRoleName = "user"
+
+// maxListedUsers caps the length of the /users reply.
+const maxListedUsers = 50
+
+// roleNamePattern restricts custom role names to something that can be typed in a command.
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// syncUserCommands shows, updates or removes the admin command palette of a user after a role
+// change. Removing the chat-scoped list makes Telegram fall back to the default (public) commands.
+func (b *Bot) syncUserCommands(ctx context.Context, user User) {
+	if commands, admin := b.userPalette(ctx, user); admin {
+		b.registerAdminCommandsForUser(ctx, user.TelegramID, commands)
+		return
+	}
+	if _, err := b.tgBot.DeleteMyCommands(ctx, &bot.DeleteMyCommandsParams{
+		Scope: &models.BotCommandScopeChat{ChatID: user.TelegramID},
+	}); err != nil {
+		ErrorLogger.Printf("Failed to remove admin commands for user %d: %v", user.TelegramID, err)
+	}
+}
+
+// resolveUser finds the target of a command given as a numeric Telegram ID or as @username.
+// Numeric IDs of users not seen yet are registered, so they can be promoted ahead of time.
+func (b *Bot) resolveUser(ref string) (User, error) {
+	if username, ok := strings.CutPrefix(ref, "@"); ok {
+		var user User
+		err := b.db.Preload("Role.Scopes").
+			Where("bot_id = ? AND LOWER(username) = LOWER(?)", b.botID, username).
+			First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return User{}, fmt.Errorf("no user @%s has talked to this bot yet", username)
+		}
+		return user, err
+	}
+
+	telegramID, err := strconv.ParseInt(ref, 10, 64)
+	if err != nil {
+		return User{}, fmt.Errorf("invalid user %q, expected a user ID or @username", ref)
+	}
+	if _, err := b.getOrCreateUser(telegramID, "", false); err != nil {
+		return User{}, err
+	}
+	var user User
+	err = b.db.Preload("Role.Scopes").Where("telegram_id = ? AND bot_id = ?", telegramID, b.botID).First(&user).Error
+	return user, err
+}
+
+// loadUser returns a user of this bot with their role and its scopes.
+func (b *Bot) loadUser(telegramID int64) (User, error) {
+	var user User
+	err := b.db.Preload("Role.Scopes").Where("telegram_id = ? AND bot_id = ?", telegramID, b.botID).First(&user).Error
+	return user, err
+}
+
+// findRole returns the role with the given name and its scopes.
+func (b *Bot) findRole(name string) (Role, error) {
+	role, err := b.getRoleByName(name)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Role{}, fmt.Errorf("unknown role %q", name)
+	}
+	return role, err
+}
+
+// ownRole returns this bot's own version of role. A global role is copied on first change, and
+// the bot's users holding it move to the copy, so the change doesn't affect other bots.
+func (b *Bot) ownRole(role Role) (Role, error) {
+	if role.BotID == b.botID {
+		return role, nil
+	}
+	own := Role{BotID: b.botID, Name: role.Name, Scopes: role.Scopes}
+	err := b.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&own).Error; err != nil {
+			return err
+		}
+		return tx.Model(&User{}).Where("bot_id = ? AND role_id = ?", b.botID, role.ID).Update("role_id", own.ID).Error
+	})
+	if err != nil {
+		return Role{}, fmt.Errorf("failed to copy role %s: %w", role.Name, err)
+	}
+	InfoLogger.Printf("[%s] Copied the global role %s for this bot", b.config.ID, role.Name)
+	return own, nil
+}
+
+// scopeNames returns the names of the scopes of role.
+func scopeNames(role Role) []string {
+	names := make([]string, 0, len(role.Scopes))
+	for _, s := range role.Scopes {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// coversRole reports whether actor holds every scope of role. Owners and co-owners cover every role.
+func (b *Bot) coversRole(actor User, role Role) bool {
+	if b.actsAsOwner(actor) {
+		return true
+	}
+	for _, scope := range role.Scopes {
+		if !roleHasScope(actor.Role, scope.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// changeUserRole assigns roleName to target on behalf of actorID and updates the target's command
+// palette. Non-owners may only manage users, and grant roles, whose scopes they hold themselves,
+// so nobody can hand out more than they have. The owner's role never changes.
+func (b *Bot) changeUserRole(ctx context.Context, actorID int64, target User, roleName string) (User, error) {
+	actor, err := b.loadUser(actorID)
+	if err != nil {
+		return User{}, fmt.Errorf("could not load your user: %w", err)
+	}
+	if target.IsOwner {
+		return User{}, errors.New("the owner's role can't be changed")
+	}
+	if roleName == "owner" {
+		return User{}, errors.New("the owner role can't be assigned")
+	}
+
+	role, err := b.findRole(roleName)
+	if err != nil {
+		return User{}, err
+	}
+	if !b.coversRole(actor, target.Role) || !b.coversRole(actor, role) {
+		return User{}, errors.New("you can only manage users and roles within your own permissions")
+	}
+
+	target.RoleID = role.ID
+	target.Role = role
+	if err := b.db.Save(&target).Error; err != nil {
+		return User{}, err
+	}
+	InfoLogger.Printf("[%s] User %d set the role of user %d to %s", b.config.ID, actorID, target.TelegramID, roleName)
+
+	if target.TelegramID != 0 {
+		b.syncUserCommands(ctx, target)
+	}
+	return target, nil
+}
+
+// userLabel renders a user as "@name (ID)" or just the ID when the username is unknown.
+func userLabel(u User) string {
+	if u.Username == "" {
+		return strconv.FormatInt(u.TelegramID, 10)
+	}
+	return fmt.Sprintf("@%s (%d)", u.Username, u.TelegramID)
+}
+
+// handlePromoteCommand handles /promote <user_id|@username> [role]; the role defaults to admin.
+func (b *Bot) handlePromoteCommand(ctx context.Context, c *commandCall) {
+	roleName := "admin"
+	if c.has("role") {
+		roleName = c.str("role")
+	}
+	b.handleRoleChange(ctx, c, roleName)
+}
+
+// handleDemoteCommand handles /demote <user_id|@username>, which resets a user to the default role.
+func (b *Bot) handleDemoteCommand(ctx context.Context, c *commandCall) {
+	b.handleRoleChange(ctx, c, defaultRoleName)
+}
+
+func (b *Bot) handleRoleChange(ctx context.Context, c *commandCall, roleName string) {
+	userID := c.userID()
+
+	target, err := b.resolveUser(c.str(userRefArg.name))
+	if err != nil {
+		c.reply(fmt.Sprintf("❌ %v", err))
+		return
+	}
+	target, err = b.changeUserRole(ctx, userID, target, roleName)
+	if err != nil {
+		c.reply(fmt.Sprintf("❌ Couldn't change the role: %v", err))
+		return
+	}
+	c.reply(fmt.Sprintf("✅ %s now has the role %s.", userLabel(target), target.Role.Name))
+}
+
+// handleUsersCommand lists the users of the bot with their roles, optionally only those of one role.
+func (b *Bot) handleUsersCommand(ctx context.Context, c *commandCall) {
+	// A fresh statement per query: GORM statements can't be reused after Count.
+	query := func() *gorm.DB {
+		q := b.db.Model(&User{}).Joins("Role").Where("users.bot_id = ? AND users.telegram_id <> 0", b.botID)
+		if c.has("role") {
+			q = q.Where("`Role`.`name` = ?", c.str("role"))
+		}
+		return q
+	}
+	var total int64
+	if err := query().Count(&total).Error; err != nil {
+		ErrorLogger.Printf("Error counting users: %v", err)
+		c.reply("Sorry, I couldn't list the users.")
+		return
+	}
+	var users []User
+	if err := query().Order("users.id").Limit(maxListedUsers).Find(&users).Error; err != nil {
+		ErrorLogger.Printf("Error listing users: %v", err)
+		c.reply("Sorry, I couldn't list the users.")
+		return
+	}
+	if len(users) == 0 {
+		c.reply("No users found.")
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "👥 Users (%d):", total)
+	for _, u := range users {
+		role := u.Role.Name
+		if u.IsOwner {
+			role = "owner"
+		}
+		fmt.Fprintf(&sb, "\n- %s — %s", userLabel(u), role)
+	}
+	if total > int64(len(users)) {
+		fmt.Fprintf(&sb, "\n…and %d more", total-int64(len(users)))
+	}
+	c.reply(sb.String())
+}
+
+// handleRolesCommand lists the roles and their scopes.
+func (b *Bot) handleRolesCommand(ctx context.Context, c *commandCall) {
+	userID := c.userID()
+
+	var roles []Role
+	if err := b.db.Preload("Scopes").Where("bot_id IN ?", []uint{0, b.botID}).Order("id").Find(&roles).Error; err != nil {
+		ErrorLogger.Printf("Error listing roles: %v", err)
+		c.reply("Sorry, I couldn't list the roles.")
+		return
+	}
+	own := make(map[string]bool)
+	for _, role := range roles {
+		if role.BotID == b.botID {
+			own[role.Name] = true
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔑 Roles:")
+	for _, role := range roles {
+		suffix := ""
+		if role.BotID == b.botID {
+			suffix = " (this bot)"
+		} else if own[role.Name] {
+			continue // shadowed by this bot's own role
+		}
+		names := scopeNames(role)
+		if len(names) == 0 {
+			names = append(names, "no scopes")
+		}
+		fmt.Fprintf(&sb, "\n\n%s%s:\n%s", role.Name, suffix, strings.Join(names, ", "))
+	}
+	if b.hasScope(userID, ScopeRoleManage) {
+		var scopes []string
+		if err := b.db.Model(&Scope{}).Order("name").Pluck("name", &scopes).Error; err != nil {
+			ErrorLogger.Printf("Error listing scopes: %v", err)
+		} else {
+			fmt.Fprintf(&sb, "\n\nAvailable scopes:\n%s", strings.Join(scopes, ", "))
+		}
+	}
+	c.reply(sb.String())
+}
+
+// grantableScopes looks up the named scopes. Only owners and co-owners may hand out scopes they
+// don't hold themselves.
+func (b *Bot) grantableScopes(actor User, names []string) ([]Scope, error) {
+	var scopes []Scope
+	if err := b.db.Where("name IN ?", names).Find(&scopes).Error; err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(scopes, func(s Scope) bool { return s.Name == name }) {
+			return nil, fmt.Errorf("unknown scope %q", name)
+		}
+		if !b.actsAsOwner(actor) && !roleHasScope(actor.Role, name) {
+			return nil, fmt.Errorf("you don't hold the scope %s yourself", name)
+		}
+	}
+	return scopes, nil
+}
+
+// syncRoleCommands refreshes the command palettes of this bot's users holding role, after its
+// scopes changed in a way that affects the palette.
+func (b *Bot) syncRoleCommands(ctx context.Context, role Role) {
+	var users []User
+	if err := b.db.Where("bot_id = ? AND role_id = ? AND telegram_id <> 0", b.botID, role.ID).Find(&users).Error; err != nil {
+		ErrorLogger.Printf("Error loading users of role %s: %v", role.Name, err)
+		return
+	}
+	for _, u := range users {
+		u.Role = role
+		b.syncUserCommands(ctx, u)
+	}
+}
+
+// handleRoleCreateCommand handles /role_create <name> [scope...], which adds a custom role to this bot.
+func (b *Bot) handleRoleCreateCommand(ctx context.Context, c *commandCall) {
+	userID := c.userID()
+
+	name := c.str("name")
+	if !roleNamePattern.MatchString(name) {
+		c.reply("Invalid role name. Use up to 32 lowercase letters, digits, '_' or '-', starting with a letter.")
+		return
+	}
+	if _, err := b.findRole(name); err == nil {
+		c.reply(fmt.Sprintf("❌ The role %s already exists.", name))
+		return
+	}
+	actor, err := b.loadUser(userID)
+	if err != nil {
+		ErrorLogger.Printf("Error loading user %d: %v", userID, err)
+		c.reply("Sorry, I couldn't create the role.")
+		return
+	}
+	scopes, err := b.grantableScopes(actor, c.rest)
+	if err != nil {
+		c.reply(fmt.Sprintf("❌ %v", err))
+		return
+	}
+
+	if err := b.db.Create(&Role{BotID: b.botID, Name: name, Scopes: scopes}).Error; err != nil {
+		ErrorLogger.Printf("Error creating role %s: %v", name, err)
+		c.reply("Sorry, I couldn't create the role.")
+		return
+	}
+	InfoLogger.Printf("[%s] User %d created the role %s with scopes %v", b.config.ID, userID, name, c.rest)
+	c.reply(fmt.Sprintf("✅ Created the role %s.", name))
+}
+
+// handleRoleDeleteCommand handles /role_delete <name>. Default roles and roles still held by
+// users can't be deleted.
+func (b *Bot) handleRoleDeleteCommand(ctx context.Context, c *commandCall) {
+	userID := c.userID()
+
+	name := c.str("name")
+	if slices.Contains(defaultRoles, name) {
+		c.reply(fmt.Sprintf("❌ The default role %s can't be deleted.", name))
+		return
+	}
+	role, err := b.findRole(name)
+	if err != nil {
+		c.reply(fmt.Sprintf("❌ %v", err))
+		return
+	}
+	if role.BotID != b.botID {
+		c.reply(fmt.Sprintf("❌ The role %s is shared by all bots and can't be deleted here.", role.Name))
+		return
+	}
+	actor, err := b.loadUser(userID)
+	if err != nil || !b.coversRole(actor, role) {
+		c.reply("❌ You can only delete roles within your own permissions.")
+		return
+	}
+	// Soft-deleted users still reference their role, so they count as well.
+	var holders int64
+	if err := b.db.Unscoped().Model(&User{}).Where("role_id = ?", role.ID).Count(&holders).Error; err != nil {
+		ErrorLogger.Printf("Error counting users of role %s: %v", role.Name, err)
+		c.reply("Sorry, I couldn't delete the role.")
+		return
+	}
+	if holders > 0 {
+		c.reply(fmt.Sprintf("❌ The role %s is still assigned to %d user(s). Reassign them with /promote or /demote first.", role.Name, holders))
+		return
+	}
+
+	// Deleted permanently so the name can be reused; the scope links go with it.
+	if err := b.db.Unscoped().Select("Scopes").Delete(&role).Error; err != nil {
+		ErrorLogger.Printf("Error deleting role %s: %v", role.Name, err)
+		c.reply("Sorry, I couldn't delete the role.")
+		return
+	}
+	InfoLogger.Printf("[%s] User %d deleted the role %s", b.config.ID, userID, role.Name)
+	c.reply(fmt.Sprintf("✅ Deleted the role %s.", role.Name))
+}
+
+// handleRoleGrantCommand handles /role_grant <role> <scope...>.
+func (b *Bot) handleRoleGrantCommand(ctx context.Context, c *commandCall) {
+	b.handleRoleScopes(ctx, c, true)
+}
+
+// handleRoleRevokeCommand handles /role_revoke <role> <scope...>.
+func (b *Bot) handleRoleRevokeCommand(ctx context.Context, c *commandCall) {
+	b.handleRoleScopes(ctx, c, false)
+}
+
+func (b *Bot) handleRoleScopes(ctx context.Context, c *commandCall, grant bool) {
+	userID := c.userID()
+	verb := "Revoked"
+	if grant {
+		verb = "Granted"
+	}
+
+	if c.str("role") == "owner" {
+		c.reply("❌ The owner role always holds every scope and can't be changed.")
+		return
+	}
+	role, err := b.findRole(c.str("role"))
+	if err != nil {
+		c.reply(fmt.Sprintf("❌ %v", err))
+		return
+	}
+	actor, err := b.loadUser(userID)
+	if err != nil || !b.coversRole(actor, role) {
+		c.reply("❌ You can only change roles within your own permissions.")
+		return
+	}
+	scopes, err := b.grantableScopes(actor, c.rest)
+	if err != nil {
+		c.reply(fmt.Sprintf("❌ %v", err))
+		return
+	}
+
+	own, err := b.ownRole(role)
+	if err != nil {
+		ErrorLogger.Printf("Error updating scopes of role %s: %v", role.Name, err)
+		c.reply("Sorry, I couldn't update the role.")
+		return
+	}
+	role = own
+	association := b.db.Model(&role).Association("Scopes")
+	if grant {
+		err = association.Append(scopes)
+	} else {
+		err = association.Delete(scopes)
+	}
+	if err != nil {
+		ErrorLogger.Printf("Error updating scopes of role %s: %v", role.Name, err)
+		c.reply("Sorry, I couldn't update the role.")
+		return
+	}
+	InfoLogger.Printf("[%s] User %d: %s %v on role %s", b.config.ID, userID, strings.ToLower(verb), c.rest, role.Name)
+
+	// The palettes list the commands the role's scopes allow.
+	if role, err = b.findRole(role.Name); err == nil {
+		b.syncRoleCommands(ctx, role)
+	}
+	c.reply(fmt.Sprintf("✅ %s %s on the role %s.", verb, strings.Join(c.rest, ", "), role.Name))
+}
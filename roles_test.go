@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRoleCommands verifies /promote, /demote, /users and /roles, including the permission checks.
+func TestRoleCommands(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	var lastSent string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+	var registered, removed []int64
+	mockTgClient.SetMyCommandsFunc = func(ctx context.Context, params *bot.SetMyCommandsParams) (bool, error) {
+		if scope, ok := params.Scope.(*models.BotCommandScopeChat); ok {
+			registered = append(registered, scope.ChatID.(int64))
+		}
+		return true, nil
+	}
+	mockTgClient.DeleteMyCommandsFunc = func(ctx context.Context, params *bot.DeleteMyCommandsParams) (bool, error) {
+		removed = append(removed, params.Scope.(*models.BotCommandScopeChat).ChatID.(int64))
+		return true, nil
+	}
+	usernames := map[int64]string{789: "Regular", 456: "helper"}
+	send := func(userID int64, text string) {
+		update := commandUpdate(userID, userID, text)
+		update.Message.From.Username = usernames[userID] // Messages keep stored usernames current
+		b.handleUpdate(context.Background(), nil, update)
+	}
+	roleOf := func(telegramID int64) string {
+		u, err := b.loadUser(telegramID)
+		assert.NoError(t, err)
+		return u.Role.Name
+	}
+
+	_, err := b.getOrCreateUser(789, "Regular", false)
+	assert.NoError(t, err)
+	_, err = b.getOrCreateUser(456, "helper", false)
+	assert.NoError(t, err)
+
+	// Regular users can't change roles or list users.
+	send(789, "/promote 456")
+	assert.Contains(t, lastSent, "Permission denied")
+	send(789, "/users")
+	assert.Contains(t, lastSent, "Permission denied")
+
+	// Promote by @username (case-insensitive) with the default role, and by ID with a role.
+	send(123, "/promote @helper")
+	assert.Equal(t, "✅ @helper (456) now has the role admin.", lastSent)
+	assert.Equal(t, []int64{456}, registered)
+	send(123, "/promote 789 admin")
+	assert.Equal(t, "admin", roleOf(789))
+	send(123, "/promote @nobody")
+	assert.Contains(t, lastSent, "no user @nobody")
+	send(123, "/promote 789 superuser")
+	assert.Contains(t, lastSent, `unknown role "superuser"`)
+
+	// The owner's role is fixed and can't be handed out.
+	send(456, "/demote 123")
+	assert.Contains(t, lastSent, "the owner's role can't be changed")
+	send(123, "/promote 789 owner")
+	assert.Contains(t, lastSent, "the owner role can't be assigned")
+
+	// Admins can't grant a role with scopes they lack.
+	var bypass Scope
+	assert.NoError(t, b.db.Where("name = ?", ScopeRateLimitBypass).First(&bypass).Error)
+	assert.NoError(t, b.db.Create(&Role{Name: "vip", Scopes: []Scope{bypass}}).Error)
+	send(456, "/promote 789 vip")
+	assert.Contains(t, lastSent, "within your own permissions")
+	send(123, "/promote 789 vip")
+	assert.Equal(t, "vip", roleOf(789))
+
+	// Demoting removes the admin palette.
+	send(123, "/demote @Helper")
+	assert.Equal(t, "✅ @helper (456) now has the role user.", lastSent)
+	assert.Equal(t, "user", roleOf(456))
+	assert.Equal(t, []int64{789, 456}, removed, "vip has no admin scopes either")
+	send(123, "/demote")
+	assert.Equal(t, "Usage: /demote <user_id|@username>", lastSent)
+
+	send(123, "/users")
+	assert.Contains(t, lastSent, "👥 Users (3):")
+	assert.Contains(t, lastSent, "123 — owner")
+	assert.Contains(t, lastSent, "@Regular (789) — vip")
+	send(123, "/users user")
+	assert.Equal(t, "👥 Users (1):\n- @helper (456) — user", lastSent)
+
+	send(123, "/roles")
+	assert.Contains(t, lastSent, "🔑 Roles:")
+	assert.Contains(t, lastSent, "vip:\n"+ScopeRateLimitBypass)
+	assert.Contains(t, lastSent, "user:\n"+ScopeStatsViewOwn)
+}
+
+// TestRoleManagement verifies /role_create, /role_grant, /role_revoke and /role_delete.
+func TestRoleManagement(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	var lastSent string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+	send := func(userID int64, text string) {
+		b.handleUpdate(context.Background(), nil, commandUpdate(userID, userID, text))
+	}
+	_, err := b.getOrCreateUser(789, "regular", false)
+	assert.NoError(t, err)
+
+	// Admins can't manage roles by default.
+	assert.NoError(t, b.promoteUserToAdmin(123, 789))
+	send(789, "/role_create vip")
+	assert.Contains(t, lastSent, "Permission denied")
+
+	send(123, "/role_create VIP")
+	assert.Contains(t, lastSent, "Invalid role name")
+	send(123, "/role_create vip "+ScopeStatsViewOwn+" nope:scope")
+	assert.Equal(t, `❌ unknown scope "nope:scope"`, lastSent)
+	send(123, "/role_create vip "+ScopeStatsViewOwn)
+	assert.Equal(t, "✅ Created the role vip.", lastSent)
+	send(123, "/role_create vip")
+	assert.Contains(t, lastSent, "already exists")
+
+	send(123, "/role_grant vip "+ScopeRateLimitBypass+" "+ScopeVisionUse)
+	assert.Contains(t, lastSent, "✅ Granted")
+	send(123, "/role_revoke vip "+ScopeStatsViewOwn)
+	assert.Contains(t, lastSent, "✅ Revoked")
+	role, err := b.findRole("vip")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{ScopeRateLimitBypass, ScopeVisionUse}, scopeNames(role))
+	send(123, "/role_grant owner "+ScopeVisionUse)
+	assert.Contains(t, lastSent, "owner role always holds every scope")
+
+	// A role in use or a default role can't be deleted.
+	send(123, "/promote 789 vip")
+	send(123, "/role_delete vip")
+	assert.Contains(t, lastSent, "still assigned to 1 user(s)")
+	send(123, "/role_delete admin")
+	assert.Contains(t, lastSent, "default role admin can't be deleted")
+	send(123, "/demote 789")
+	send(123, "/role_delete vip")
+	assert.Equal(t, "✅ Deleted the role vip.", lastSent)
+	_, err = b.findRole("vip")
+	assert.Error(t, err)
+}
+
+// TestCreateDefaultScopes_KeepsChanges verifies that reseeding on startup doesn't undo scope
+// changes but still grants newly introduced scopes.
+func TestCreateDefaultScopes_KeepsChanges(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	db := setupTestDB(t)
+	var admin Role
+	assert.NoError(t, db.Preload("Scopes").Where("name = ?", "admin").First(&admin).Error)
+	assert.Contains(t, scopeNames(admin), ScopeUserBan)
+
+	// Revoke a scope, and pretend user:list is new in this release.
+	var ban, list Scope
+	assert.NoError(t, db.Where("name = ?", ScopeUserBan).First(&ban).Error)
+	assert.NoError(t, db.Where("name = ?", ScopeUserList).First(&list).Error)
+	assert.NoError(t, db.Model(&admin).Association("Scopes").Delete(&ban))
+	assert.NoError(t, db.Model(&admin).Association("Scopes").Delete(&list))
+	assert.NoError(t, db.Unscoped().Delete(&list).Error)
+
+	assert.NoError(t, createDefaultScopes(db))
+	assert.NoError(t, db.Preload("Scopes").Where("name = ?", "admin").First(&admin).Error)
+	assert.NotContains(t, scopeNames(admin), ScopeUserBan)
+	assert.Contains(t, scopeNames(admin), ScopeUserList)
+}
+
+// TestPerBotRoles verifies that role changes on one bot don't affect another bot sharing the database.
+func TestPerBotRoles(t *testing.T) {
+	db := setupTestDB(t)
+	newBot := func(id string) (*Bot, *MockTelegramClient) {
+		mockTgClient := &MockTelegramClient{}
+		b, err := NewBot(db, BotConfig{ID: id, OwnerTelegramID: 123, TempBanDuration: "1h", SystemPrompts: map[string]string{}},
+			&MockClock{currentTime: time.Now()}, mockTgClient)
+		assert.NoError(t, err)
+		return b, mockTgClient
+	}
+	botA, mockA := newBot("bot_a")
+	botB, _ := newBot("bot_b")
+	var lastSent string
+	mockA.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+	send := func(text string) {
+		botA.handleUpdate(context.Background(), nil, commandUpdate(123, 123, text))
+	}
+	for _, b := range []*Bot{botA, botB} {
+		_, err := b.getOrCreateUser(456, "helper", false)
+		assert.NoError(t, err)
+		assert.NoError(t, b.promoteUserToAdmin(123, 456))
+	}
+
+	// Changing "admin" on bot A copies the role for bot A only.
+	send("/role_grant admin " + ScopeRateLimitBypass)
+	assert.Contains(t, lastSent, "✅ Granted")
+	assert.True(t, botA.hasScope(456, ScopeRateLimitBypass))
+	assert.False(t, botB.hasScope(456, ScopeRateLimitBypass))
+	adminA, err := botA.getRoleByName("admin")
+	assert.NoError(t, err)
+	assert.Equal(t, botA.botID, adminA.BotID)
+	adminB, err := botB.getRoleByName("admin")
+	assert.NoError(t, err)
+	assert.Zero(t, adminB.BotID)
+
+	// A custom role exists only on the bot that created it.
+	send("/role_create moderator " + ScopeUserBan)
+	assert.Contains(t, lastSent, "✅ Created")
+	_, err = botB.findRole("moderator")
+	assert.Error(t, err)
+	_, err = botB.changeUserRole(context.Background(), 123, User{TelegramID: 0}, "moderator")
+	assert.EqualError(t, err, `unknown role "moderator"`)
+
+	send("/roles")
+	assert.Contains(t, lastSent, "admin (this bot):")
+	assert.Contains(t, lastSent, "moderator (this bot):")
+	assert.Contains(t, lastSent, "\n\nuser:\n")
+	assert.NotContains(t, lastSent, "\n\nadmin:\n")
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-telegram/bot"
+)
+
+const (
+	// streamPlaceholder is sent immediately so the user sees the bot is answering.
+	streamPlaceholder = "…"
+	// defaultStreamEditInterval keeps edits comfortably below Telegram's ~1 edit/second
+	// per-chat limit; groups are stricter (~20 messages/minute), so raise it there.
+	defaultStreamEditInterval = 1500 * time.Millisecond
+)
+
+// streamEditInterval returns the configured minimum time between progressive edits.
+func (b *Bot) streamEditInterval() time.Duration {
+	if d, err := time.ParseDuration(b.config.StreamEditInterval); err == nil && d > 0 {
+		return d
+	}
+	return defaultStreamEditInterval
+}
+
+// sendStreamingResponse sends a placeholder message and edits it as the completion streams in,
+// at most once per streamEditInterval. The final text (or the error fallback) is persisted through
+// screenOutgoingMessage exactly once, after the stream has finished. Edits stop at Telegram's
+// message length limit; the rest of a longer reply is sent as further messages at the end.
+func (b *Bot) sendStreamingResponse(ctx context.Context, chatID, userID int64, request CompletionRequest, streamer StreamingProvider, businessConnectionID string) error {
+	b.fitRequestToBudget(ctx, &request)
+	placeholder, err := b.tgBot.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:               chatID,
+		Text:                 streamPlaceholder,
+		BusinessConnectionID: businessConnectionID,
+		ReplyParameters:      replyParameters(ctx),
+	})
+	if err != nil {
+		return fmt.Errorf("error sending streaming placeholder: %w", err)
+	}
+
+	shown := streamPlaceholder
+	edit := func(text string) error {
+		if strings.TrimSpace(text) == "" || text == shown {
+			// Telegram rejects empty edits and edits that do not change the text.
+			return nil
+		}
+		if _, err := b.tgBot.EditMessageText(ctx, &bot.EditMessageTextParams{
+			ChatID:               chatID,
+			MessageID:            placeholder.ID,
+			Text:                 text,
+			BusinessConnectionID: businessConnectionID,
+		}); err != nil {
+			return err
+		}
+		shown = text
+		return nil
+	}
+
+	var partial strings.Builder
+	interval := b.streamEditInterval()
+	lastEdit := b.clock.Now()
+
+	resp, err := streamer.CompleteStream(ctx, request, func(delta string) {
+		partial.WriteString(delta)
+		if now := b.clock.Now(); now.Sub(lastEdit) >= interval && messageLength(partial.String()) <= telegramMessageLimit {
+			lastEdit = now
+			if err := edit(partial.String()); err != nil {
+				ErrorLogger.Printf("[%s] Error editing streamed message in chat %d: %v", b.config.ID, chatID, err)
+			}
+		}
+	})
+
+	final := resp.Text
+	if err != nil {
+		ErrorLogger.Printf("Error streaming Anthropic response: %v", err)
+		final = b.anthropicErrorResponse(err, userID)
+	} else {
+		b.recordUsage(ctx, request.Model, resp.Usage)
+	}
+
+	if _, err := b.screenOutgoingMessage(ctx, chatID, final); err != nil {
+		ErrorLogger.Printf("Error storing streamed assistant message: %v", err)
+	}
+
+	parts := splitMessage(final, telegramMessageLimit)
+	if err := edit(parts[0]); err != nil {
+		return fmt.Errorf("error editing streamed message: %w", err)
+	}
+	for _, part := range parts[1:] {
+		if err := b.sendText(ctx, chatID, part, businessConnectionID, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSendStreamingResponse verifies that a streamed reply is shown as a placeholder, edited at
+// most once per interval, finished with the full text, and persisted exactly once.
+func TestSendStreamingResponse(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	b.config.StreamResponses = true
+	b.config.StreamEditInterval = "1s"
+	clock := b.clock.(*MockClock)
+
+	b.llm = &MockLLMProvider{
+		CompleteStreamFunc: func(ctx context.Context, req CompletionRequest, onDelta func(string)) (CompletionResponse, error) {
+			onDelta("Hel") // same instant as the placeholder: throttled
+			clock.Advance(2 * time.Second)
+			onDelta("lo") // interval elapsed: edited
+			onDelta(" wor")
+			clock.Advance(500 * time.Millisecond)
+			onDelta("ld") // within interval: throttled
+			return CompletionResponse{Text: "Hello world"}, nil
+		},
+	}
+
+	var sent []string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		sent = append(sent, params.Text)
+		return &models.Message{ID: 42}, nil
+	}
+	var edits []string
+	mockTgClient.EditMessageTextFunc = func(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error) {
+		assert.Equal(t, 42, params.MessageID)
+		edits = append(edits, params.Text)
+		return &models.Message{}, nil
+	}
+
+	b.handleUpdate(context.Background(), nil, &models.Update{
+		Message: &models.Message{
+			Chat: models.Chat{ID: 789},
+			From: &models.User{ID: 789, Username: "regular"},
+			Text: "Hi",
+		},
+	})
+
+	assert.Equal(t, []string{streamPlaceholder}, sent)
+	assert.Equal(t, []string{"Hello", "Hello world"}, edits)
+
+	var stored []Message
+	assert.NoError(t, b.db.Where("chat_id = ? AND is_user = ?", 789, false).Find(&stored).Error)
+	if assert.Len(t, stored, 1) {
+		assert.Equal(t, "Hello world", stored[0].Text)
+	}
+}
+
+// TestSendStreamingResponse_Error verifies that a failed stream replaces the placeholder
+// with the generic error reply and stores that reply once.
+func TestSendStreamingResponse_Error(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, mockTgClient := setupBotForTest(t, 123)
+	b.config.StreamResponses = true
+	b.llm = &MockLLMProvider{
+		CompleteStreamFunc: func(ctx context.Context, req CompletionRequest, onDelta func(string)) (CompletionResponse, error) {
+			return CompletionResponse{}, errors.New("stream broke")
+		},
+	}
+
+	var edits []string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		return &models.Message{ID: 1}, nil
+	}
+	mockTgClient.EditMessageTextFunc = func(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error) {
+		edits = append(edits, params.Text)
+		return &models.Message{}, nil
+	}
+
+	b.handleUpdate(context.Background(), nil, &models.Update{
+		Message: &models.Message{
+			Chat: models.Chat{ID: 789},
+			From: &models.User{ID: 789, Username: "regular"},
+			Text: "Hi",
+		},
+	})
+
+	assert.Equal(t, []string{"I'm sorry, I'm having trouble processing your request right now."}, edits)
+	var count int64
+	b.db.Model(&Message{}).Where("chat_id = ? AND is_user = ?", 789, false).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+// TestSplitMessage verifies splitting at Telegram's limit, preferring line and word breaks and
+// counting characters outside the BMP twice.
+func TestSplitMessage(t *testing.T) {
+	assert.Equal(t, []string{"short"}, splitMessage("short", 10))
+	assert.Equal(t, []string{"first line\n", "second"}, splitMessage("first line\nsecond", 12))
+	assert.Equal(t, []string{"abcdefghij", "klm"}, splitMessage("abcdefghijklm", 10), "no break in the second half")
+	assert.Equal(t, []string{"😀😀", "😀"}, splitMessage("😀😀😀", 4))
+}
+
+// TestSendStreamingResponse_Long verifies that edits stop at Telegram's message limit, the rest of
+// the reply follows as new messages, and a failed final edit is reported.
+func TestSendStreamingResponse_Long(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, mockTgClient := setupBotForTest(t, 123)
+	b.config.StreamEditInterval = "1s"
+	clock := b.clock.(*MockClock)
+	long := strings.Repeat("word ", 1000) // 5000 characters
+
+	streamer := &MockLLMProvider{
+		CompleteStreamFunc: func(ctx context.Context, req CompletionRequest, onDelta func(string)) (CompletionResponse, error) {
+			clock.Advance(2 * time.Second)
+			onDelta(long[:4000])
+			clock.Advance(2 * time.Second)
+			onDelta(long[4000:]) // over the limit: not edited
+			return CompletionResponse{Text: long}, nil
+		},
+	}
+	var sent, edits []string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		sent = append(sent, params.Text)
+		return &models.Message{ID: 42}, nil
+	}
+	var editErr error
+	mockTgClient.EditMessageTextFunc = func(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error) {
+		assert.LessOrEqual(t, messageLength(params.Text), telegramMessageLimit)
+		edits = append(edits, params.Text)
+		return &models.Message{}, editErr
+	}
+
+	assert.NoError(t, b.sendStreamingResponse(context.Background(), 789, 789, CompletionRequest{}, streamer, ""))
+	parts := splitMessage(long, telegramMessageLimit)
+	assert.Equal(t, []string{long[:4000], parts[0]}, edits)
+	assert.Equal(t, []string{streamPlaceholder, parts[1]}, sent)
+	assert.Equal(t, long, strings.Join(parts, ""))
+
+	editErr = errors.New("message is too long")
+	assert.ErrorContains(t, b.sendStreamingResponse(context.Background(), 789, 789, CompletionRequest{}, streamer, ""), "message is too long")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"gorm.io/gorm"
+)
+
+// summaryBatchSize caps how many evicted messages are folded into the summary per update, so a
+// long backlog (e.g. right after enabling summaries) is caught up over several turns.
+const summaryBatchSize = 50
+
+const summaryPrompt = "You maintain a running summary of a chat between users and an AI assistant. " +
+	"Update the existing summary with the new messages. Keep names, facts, preferences, decisions and open questions; " +
+	"drop small talk. Reply with the updated summary only, in at most 200 words."
+
+// getChatSummary returns the stored summary of a chat, or an empty one for the chat when none exists.
+func (b *Bot) getChatSummary(chatID int64) (ChatSummary, error) {
+	var summary ChatSummary
+	err := b.db.Where("bot_id = ? AND chat_id = ?", b.botID, chatID).First(&summary).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ChatSummary{BotID: b.botID, ChatID: chatID}, nil
+	}
+	return summary, err
+}
+
+// chatSummaryText returns the summary text injected into the system prompt, or "" when there is none.
+func (b *Bot) chatSummaryText(chatID int64) string {
+	summary, err := b.getChatSummary(chatID)
+	if err != nil {
+		ErrorLogger.Printf("Error loading summary for chat %d: %v", chatID, err)
+		return ""
+	}
+	return summary.Summary
+}
+
+// updateChatSummary folds the messages that have left the context window, and are not yet in
+// the summary, into the chat's rolling summary. It works from the database rather than from the
+// evicted slice so nothing is lost across restarts. Failures are logged and retried next turn.
+func (b *Bot) updateChatSummary(ctx context.Context, chatID int64, chatMemory *ChatMemory) {
+	if !b.config.SummarizeHistory {
+		return
+	}
+
+	// Everything older than the context window is summarized, including messages still held in
+	// memory but trimmed by the token budget.
+	b.chatMemoriesMu.RLock()
+	var oldestID uint
+	if window := b.contextWindow(chatMemory); len(window) > 0 {
+		oldestID = window[0].ID
+	}
+	b.chatMemoriesMu.RUnlock()
+	if oldestID == 0 {
+		return
+	}
+
+	summary, err := b.getChatSummary(chatID)
+	if err != nil {
+		ErrorLogger.Printf("Error loading summary for chat %d: %v", chatID, err)
+		return
+	}
+
+	var evicted []Message
+	if err := b.db.Where("chat_id = ? AND bot_id = ? AND id > ? AND id < ?", chatID, b.botID, summary.LastMessageID, oldestID).
+		Order("id").
+		Limit(summaryBatchSize).
+		Find(&evicted).Error; err != nil {
+		ErrorLogger.Printf("Error fetching messages to summarize for chat %d: %v", chatID, err)
+		return
+	}
+	if len(evicted) == 0 {
+		return
+	}
+
+	var transcript strings.Builder
+	if summary.Summary != "" {
+		fmt.Fprintf(&transcript, "Existing summary:\n%s\n\n", summary.Summary)
+	}
+	transcript.WriteString("New messages:\n")
+	for _, msg := range evicted {
+		speaker := "Assistant"
+		if msg.IsUser {
+			speaker = speakerLabel(msg)
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", speaker, msg.Text)
+	}
+
+	resp, err := b.llm.Complete(ctx, CompletionRequest{
+		Model:     string(b.config.Model),
+		System:    summaryPrompt,
+		Messages:  []anthropic.Message{anthropic.NewUserTextMessage(transcript.String())},
+		MaxTokens: 500,
+	})
+	if err != nil {
+		ErrorLogger.Printf("Error summarizing chat %d: %v", chatID, err)
+		return
+	}
+	// Billed with the reply being prepared, i.e. to the user whose message triggered the summary.
+	b.recordUsage(ctx, string(b.config.Model), resp.Usage)
+
+	summary.Summary = strings.TrimSpace(resp.Text)
+	summary.LastMessageID = evicted[len(evicted)-1].ID
+	if err := b.db.Save(&summary).Error; err != nil {
+		ErrorLogger.Printf("Error saving summary for chat %d: %v", chatID, err)
+		return
+	}
+	InfoLogger.Printf("[%s] Folded %d messages into the summary of chat %d", b.config.ID, len(evicted), chatID)
+}
+
+// deleteChatSummary removes the summary of a chat. Summaries are derived data without soft
+// delete, so they are removed even on a soft /clear.
+func (b *Bot) deleteChatSummary(chatID int64) error {
+	return b.db.Where("bot_id = ? AND chat_id = ?", b.botID, chatID).Delete(&ChatSummary{}).Error
+}
+
+// sendSummary shows the rolling summary of the current chat.
+func (b *Bot) sendSummary(ctx context.Context, chatID int64, businessConnectionID string) {
+	text := "There is no summary of this chat yet."
+	if !b.config.SummarizeHistory {
+		text = "Conversation summaries are not enabled for this bot."
+	}
+	if summary := b.chatSummaryText(chatID); summary != "" {
+		text = "📝 Summary of the earlier conversation:\n\n" + summary
+	}
+	if err := b.sendResponse(ctx, chatID, text, businessConnectionID); err != nil {
+		ErrorLogger.Printf("Error sending summary: %v", err)
+	}
+}
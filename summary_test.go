@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUpdateChatSummary verifies that messages leaving the memory window are folded into the
+// rolling summary exactly once and that the summary is injected into the system prompt.
+func TestUpdateChatSummary(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	b.config.SummarizeHistory = true
+	b.memorySize = 1 // keep one exchange (two messages) in memory
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		return &models.Message{}, nil
+	}
+
+	var summaryInputs []string
+	var replySystems []string
+	b.llm = &MockLLMProvider{CompleteFunc: func(_ context.Context, req CompletionRequest) (CompletionResponse, error) {
+		if req.System == summaryPrompt {
+			input := *req.Messages[0].Content[0].Text
+			summaryInputs = append(summaryInputs, input)
+			return CompletionResponse{Text: fmt.Sprintf("summary #%d", len(summaryInputs))}, nil
+		}
+		replySystems = append(replySystems, req.System)
+		return CompletionResponse{Text: "reply"}, nil
+	}}
+
+	for _, text := range []string{"first", "second", "third"} {
+		b.handleUpdate(context.Background(), nil, &models.Update{Message: &models.Message{
+			Chat: models.Chat{ID: 789},
+			From: &models.User{ID: 789, Username: "regular"},
+			Text: text,
+		}})
+	}
+
+	// Turn 2 evicts "first"; turn 3 evicts the first reply and "second".
+	if assert.Len(t, summaryInputs, 2) {
+		assert.Equal(t, "New messages:\n@regular: first\n", summaryInputs[0])
+		assert.Equal(t, "Existing summary:\nsummary #1\n\nNew messages:\nAssistant: reply\n@regular: second\n", summaryInputs[1])
+	}
+	if assert.Len(t, replySystems, 3) {
+		assert.NotContains(t, replySystems[0], "Summary of the earlier conversation")
+		assert.True(t, strings.HasSuffix(replySystems[2], "Summary of the earlier conversation:\nsummary #2"))
+	}
+
+	summary, err := b.getChatSummary(789)
+	assert.NoError(t, err)
+	assert.Equal(t, "summary #2", summary.Summary)
+}
+
+// TestSummaryCommandAndClear verifies /summary output and that /clear wipes the summary.
+func TestSummaryCommandAndClear(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	b.config.SummarizeHistory = true
+
+	var lastSent string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+	command := func(text string) {
+		b.handleUpdate(context.Background(), nil, commandUpdate(789, 789, text))
+	}
+
+	command("/summary")
+	assert.Equal(t, "There is no summary of this chat yet.", lastSent)
+
+	assert.NoError(t, b.db.Create(&ChatSummary{BotID: b.botID, ChatID: 789, Summary: "We talked about Go."}).Error)
+	command("/summary")
+	assert.Contains(t, lastSent, "We talked about Go.")
+
+	command("/clear")
+	assert.Equal(t, "Your chat history has been cleared.", lastSent)
+	assert.Equal(t, "", b.chatSummaryText(789))
+}
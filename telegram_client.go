@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -11,9 +12,17 @@ import (
 // TelegramClient defines the methods required from the Telegram bot.
 type TelegramClient interface {
 	SendMessage(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error)
+	EditMessageText(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error)
 	SendAudio(ctx context.Context, params *bot.SendAudioParams) (*models.Message, error)
 	SetMyCommands(ctx context.Context, params *bot.SetMyCommandsParams) (bool, error)
+	DeleteMyCommands(ctx context.Context, params *bot.DeleteMyCommandsParams) (bool, error)
 	GetFile(ctx context.Context, params *bot.GetFileParams) (*models.File, error)
 	FileDownloadLink(f *models.File) string
+	GetMe(ctx context.Context) (*models.User, error)
+	GetChatMember(ctx context.Context, params *bot.GetChatMemberParams) (*models.ChatMember, error)
+	SetWebhook(ctx context.Context, params *bot.SetWebhookParams) (bool, error)
+	DeleteWebhook(ctx context.Context, params *bot.DeleteWebhookParams) (bool, error)
+	WebhookHandler() http.HandlerFunc
 	Start(ctx context.Context)
+	StartWebhook(ctx context.Context)
 }
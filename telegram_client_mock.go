@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -13,11 +14,19 @@ import (
 type MockTelegramClient struct {
 	mock.Mock
 	SendMessageFunc      func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error)
+	EditMessageTextFunc  func(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error)
 	SendAudioFunc        func(ctx context.Context, params *bot.SendAudioParams) (*models.Message, error)
 	SetMyCommandsFunc    func(ctx context.Context, params *bot.SetMyCommandsParams) (bool, error)
+	DeleteMyCommandsFunc func(ctx context.Context, params *bot.DeleteMyCommandsParams) (bool, error)
 	GetFileFunc          func(ctx context.Context, params *bot.GetFileParams) (*models.File, error)
 	FileDownloadLinkFunc func(f *models.File) string
+	GetMeFunc            func(ctx context.Context) (*models.User, error)
+	GetChatMemberFunc    func(ctx context.Context, params *bot.GetChatMemberParams) (*models.ChatMember, error)
+	SetWebhookFunc       func(ctx context.Context, params *bot.SetWebhookParams) (bool, error)
+	DeleteWebhookFunc    func(ctx context.Context, params *bot.DeleteWebhookParams) (bool, error)
+	WebhookHandlerFunc   func() http.HandlerFunc
 	StartFunc            func(ctx context.Context)
+	StartWebhookFunc     func(ctx context.Context)
 }
 
 // SendMessage mocks sending a message.
@@ -32,6 +41,14 @@ func (m *MockTelegramClient) SendMessage(ctx context.Context, params *bot.SendMe
 	return nil, args.Error(1)
 }
 
+// EditMessageText mocks editing the text of a previously sent message.
+func (m *MockTelegramClient) EditMessageText(ctx context.Context, params *bot.EditMessageTextParams) (*models.Message, error) {
+	if m.EditMessageTextFunc != nil {
+		return m.EditMessageTextFunc(ctx, params)
+	}
+	return &models.Message{}, nil
+}
+
 // SetMyCommands mocks registering bot commands.
 func (m *MockTelegramClient) SetMyCommands(ctx context.Context, params *bot.SetMyCommandsParams) (bool, error) {
 	if m.SetMyCommandsFunc != nil {
@@ -40,6 +57,14 @@ func (m *MockTelegramClient) SetMyCommands(ctx context.Context, params *bot.SetM
 	return true, nil
 }
 
+// DeleteMyCommands mocks removing a scoped command list.
+func (m *MockTelegramClient) DeleteMyCommands(ctx context.Context, params *bot.DeleteMyCommandsParams) (bool, error) {
+	if m.DeleteMyCommandsFunc != nil {
+		return m.DeleteMyCommandsFunc(ctx, params)
+	}
+	return true, nil
+}
+
 // SendAudio mocks sending an audio message.
 func (m *MockTelegramClient) SendAudio(ctx context.Context, params *bot.SendAudioParams) (*models.Message, error) {
 	if m.SendAudioFunc != nil {
@@ -64,6 +89,39 @@ func (m *MockTelegramClient) FileDownloadLink(f *models.File) string {
 	return ""
 }
 
+// SetWebhook mocks registering the webhook URL with Telegram.
+func (m *MockTelegramClient) SetWebhook(ctx context.Context, params *bot.SetWebhookParams) (bool, error) {
+	if m.SetWebhookFunc != nil {
+		return m.SetWebhookFunc(ctx, params)
+	}
+	return true, nil
+}
+
+// DeleteWebhook mocks removing the webhook from Telegram.
+func (m *MockTelegramClient) DeleteWebhook(ctx context.Context, params *bot.DeleteWebhookParams) (bool, error) {
+	if m.DeleteWebhookFunc != nil {
+		return m.DeleteWebhookFunc(ctx, params)
+	}
+	return true, nil
+}
+
+// WebhookHandler mocks the HTTP handler that receives webhook updates.
+func (m *MockTelegramClient) WebhookHandler() http.HandlerFunc {
+	if m.WebhookHandlerFunc != nil {
+		return m.WebhookHandlerFunc()
+	}
+	return func(w http.ResponseWriter, r *http.Request) {}
+}
+
+// StartWebhook mocks processing webhook updates until ctx is cancelled.
+func (m *MockTelegramClient) StartWebhook(ctx context.Context) {
+	if m.StartWebhookFunc != nil {
+		m.StartWebhookFunc(ctx)
+		return
+	}
+	<-ctx.Done()
+}
+
 // Start mocks starting the Telegram client.
 func (m *MockTelegramClient) Start(ctx context.Context) {
 	if m.StartFunc != nil {
@@ -72,3 +130,19 @@ func (m *MockTelegramClient) Start(ctx context.Context) {
 	}
 	m.Called(ctx)
 }
+
+// GetMe mocks fetching the bot's own user; defaults to a bot named test_bot.
+func (m *MockTelegramClient) GetMe(ctx context.Context) (*models.User, error) {
+	if m.GetMeFunc != nil {
+		return m.GetMeFunc(ctx)
+	}
+	return &models.User{ID: 999, IsBot: true, Username: "test_bot"}, nil
+}
+
+// GetChatMember mocks fetching a chat member; defaults to a regular member.
+func (m *MockTelegramClient) GetChatMember(ctx context.Context, params *bot.GetChatMemberParams) (*models.ChatMember, error) {
+	if m.GetChatMemberFunc != nil {
+		return m.GetChatMemberFunc(ctx, params)
+	}
+	return &models.ChatMember{Type: models.ChatMemberTypeMember, Member: &models.ChatMemberMember{}}, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/liushuangls/go-anthropic/v2"
+)
+
+// maxToolRounds bounds how many tool_use -> tool_result round trips a single reply may take,
+// so a model that keeps calling tools cannot loop (and bill) forever.
+const maxToolRounds = 5
+
+// toolNamePattern matches the tool names accepted by the Anthropic API.
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Tool is a Go function exposed to the model. The tool is only offered to users holding Scope
+// (empty means everyone); Run receives the raw JSON input described by InputSchema and returns
+// the text handed back to the model as the tool_result.
+type Tool struct {
+	Name        string
+	Description string
+	Scope       string
+	InputSchema map[string]any
+	Run         func(ctx context.Context, b *Bot, userID int64, input json.RawMessage) (string, error)
+}
+
+// ToolRegistry holds the tools available to a bot, in registration order.
+type ToolRegistry struct {
+	tools map[string]Tool
+	order []string
+}
+
+func newToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]Tool)}
+}
+
+// Register adds a tool. Names must be unique and match the API's naming rules.
+func (r *ToolRegistry) Register(tool Tool) error {
+	if !toolNamePattern.MatchString(tool.Name) {
+		return fmt.Errorf("invalid tool name %q", tool.Name)
+	}
+	if tool.Run == nil {
+		return fmt.Errorf("tool %s has no Run function", tool.Name)
+	}
+	if _, exists := r.tools[tool.Name]; exists {
+		return fmt.Errorf("tool %s is already registered", tool.Name)
+	}
+	r.tools[tool.Name] = tool
+	r.order = append(r.order, tool.Name)
+	return nil
+}
+
+// Get looks a tool up by name.
+func (r *ToolRegistry) Get(name string) (Tool, bool) {
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// All returns every registered tool in registration order.
+func (r *ToolRegistry) All() []Tool {
+	all := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		all = append(all, r.tools[name])
+	}
+	return all
+}
+
+// toolDefinitions returns the definitions of the tools the user is allowed to call,
+// or nil when tool use is disabled for this bot.
+func (b *Bot) toolDefinitions(userID int64) []anthropic.ToolDefinition {
+	if !b.config.EnableTools || b.tools == nil {
+		return nil
+	}
+	var defs []anthropic.ToolDefinition
+	for _, tool := range b.tools.All() {
+		if tool.Scope != "" && !b.hasScope(userID, tool.Scope) {
+			continue
+		}
+		defs = append(defs, anthropic.ToolDefinition{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.InputSchema,
+		})
+	}
+	return defs
+}
+
+// completeWithTools runs the completion, executing any requested tools and feeding their results
+// back until the model produces a final answer. Intermediate tool turns are not persisted; only the
+// final text reaches the chat history. Usage is summed across all rounds.
+func (b *Bot) completeWithTools(ctx context.Context, request CompletionRequest, userID int64) (CompletionResponse, error) {
+	request.Tools = b.toolDefinitions(userID)
+	// Copy so tool turns are never appended into the caller's context slice.
+	request.Messages = append([]anthropic.Message(nil), request.Messages...)
+	b.fitRequestToBudget(ctx, &request)
+
+	var usage Usage
+	for round := 0; ; round++ {
+		resp, err := b.llm.Complete(ctx, request)
+		if err != nil {
+			return CompletionResponse{}, err
+		}
+		usage.Add(resp.Usage)
+
+		if len(resp.ToolCalls) == 0 {
+			resp.Usage = usage
+			return resp, nil
+		}
+		if round >= maxToolRounds {
+			return CompletionResponse{}, fmt.Errorf("model requested tools more than %d times in one reply", maxToolRounds)
+		}
+
+		assistantTurn := anthropic.Message{Role: anthropic.RoleAssistant}
+		if resp.Text != "" {
+			assistantTurn.Content = append(assistantTurn.Content, anthropic.NewTextMessageContent(resp.Text))
+		}
+		resultTurn := anthropic.Message{Role: anthropic.RoleUser}
+		for _, call := range resp.ToolCalls {
+			assistantTurn.Content = append(assistantTurn.Content, anthropic.NewToolUseMessageContent(call.ID, call.Name, call.Input))
+			result, isError := b.runToolCall(ctx, userID, call)
+			resultTurn.Content = append(resultTurn.Content, anthropic.NewToolResultMessageContent(call.ID, result, isError))
+		}
+		request.Messages = append(request.Messages, assistantTurn, resultTurn)
+	}
+}
+
+// runToolCall executes one tool call and reports whether the result is an error.
+// Scopes are re-checked here because the model may name a tool it was not offered.
+func (b *Bot) runToolCall(ctx context.Context, userID int64, call ToolCall) (string, bool) {
+	tool, ok := b.tools.Get(call.Name)
+	if !ok {
+		return fmt.Sprintf("unknown tool %q", call.Name), true
+	}
+	if tool.Scope != "" && !b.hasScope(userID, tool.Scope) {
+		InfoLogger.Printf("User %d attempted to use tool %s without scope %s", userID, tool.Name, tool.Scope)
+		return "permission denied", true
+	}
+
+	result, err := tool.Run(ctx, b, userID, call.Input)
+	if err != nil {
+		InfoLogger.Printf("Tool %s failed for user %d: %v", tool.Name, userID, err)
+		return err.Error(), true
+	}
+	InfoLogger.Printf("Tool %s executed for user %d", tool.Name, userID)
+	return result, false
+}
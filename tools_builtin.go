@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxExpressionLength caps calculator input so a single call stays cheap.
+const maxExpressionLength = 256
+
+// builtinTools returns the tools every bot registers when tool use is enabled.
+func builtinTools() []Tool {
+	return []Tool{
+		{
+			Name:        "get_current_time",
+			Description: "Get the current date and time in a given IANA timezone (e.g. Europe/Berlin). Defaults to UTC.",
+			Scope:       ScopeToolTime,
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"timezone": map[string]any{
+						"type":        "string",
+						"description": "IANA timezone name, e.g. America/New_York",
+					},
+				},
+			},
+			Run: runCurrentTimeTool,
+		},
+		{
+			Name:        "get_my_stats",
+			Description: "Get message statistics for the user you are talking to: messages sent, received and total.",
+			Scope:       ScopeToolUserStats,
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{},
+			},
+			Run: runUserStatsTool,
+		},
+		{
+			Name:        "calculate",
+			Description: "Evaluate an arithmetic expression with + - * / % ^ and parentheses, e.g. (2+3)*4^2.",
+			Scope:       ScopeToolCalculator,
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"expression": map[string]any{
+						"type":        "string",
+						"description": "The arithmetic expression to evaluate",
+					},
+				},
+				"required": []string{"expression"},
+			},
+			Run: runCalculatorTool,
+		},
+	}
+}
+
+func runCurrentTimeTool(_ context.Context, b *Bot, _ int64, input json.RawMessage) (string, error) {
+	var args struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+	if args.Timezone == "" {
+		args.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(args.Timezone)
+	if err != nil {
+		return "", fmt.Errorf("unknown timezone %q", args.Timezone)
+	}
+	return b.clock.Now().In(loc).Format("Monday, 2006-01-02 15:04:05 MST"), nil
+}
+
+func runUserStatsTool(_ context.Context, b *Bot, userID int64, _ json.RawMessage) (string, error) {
+	_, messagesIn, messagesOut, totalMessages, err := b.getUserStats(userID)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Messages sent: %d\nMessages received: %d\nTotal messages: %d", messagesIn, messagesOut, totalMessages), nil
+}
+
+func runCalculatorTool(_ context.Context, _ *Bot, _ int64, input json.RawMessage) (string, error) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return "", fmt.Errorf("invalid input: %w", err)
+	}
+	result, err := evaluateExpression(args.Expression)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(result, 'g', -1, 64), nil
+}
+
+// evaluateExpression evaluates an arithmetic expression using a small recursive-descent parser:
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = power { ("*" | "/" | "%") power }
+//	power  = unary [ "^" power ]
+//	unary  = ("+" | "-") unary | primary
+//	primary = number | "(" expr ")"
+func evaluateExpression(expr string) (float64, error) {
+	if len(expr) > maxExpressionLength {
+		return 0, fmt.Errorf("expression too long (max %d characters)", maxExpressionLength)
+	}
+	p := &exprParser{input: expr}
+	result, err := p.parseExpr()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos)
+	}
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0, errors.New("result is not a finite number")
+	}
+	return result, nil
+}
+
+type exprParser struct {
+	input string
+	pos   int
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// peek returns the next non-space byte, or 0 at the end of input.
+func (p *exprParser) peek() byte {
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) parseExpr() (float64, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			left += right
+		} else {
+			left -= right
+		}
+	}
+}
+
+func (p *exprParser) parseTerm() (float64, error) {
+	left, err := p.parsePower()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parsePower()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			left *= right
+		case '/':
+			if right == 0 {
+				return 0, errors.New("division by zero")
+			}
+			left /= right
+		case '%':
+			if right == 0 {
+				return 0, errors.New("division by zero")
+			}
+			left = math.Mod(left, right)
+		}
+	}
+}
+
+func (p *exprParser) parsePower() (float64, error) {
+	base, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() != '^' {
+		return base, nil
+	}
+	p.pos++
+	exponent, err := p.parsePower() // right-associative
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exponent), nil
+}
+
+func (p *exprParser) parseUnary() (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		v, err := p.parseUnary()
+		return -v, err
+	case '+':
+		p.pos++
+		return p.parseUnary()
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (float64, error) {
+	if p.peek() == '(' {
+		p.pos++
+		v, err := p.parseExpr()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, errors.New("missing closing parenthesis")
+		}
+		p.pos++
+		return v, nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && strings.IndexByte("0123456789.", p.input[p.pos]) >= 0 {
+		p.pos++
+	}
+	if start == p.pos {
+		if p.pos >= len(p.input) {
+			return 0, errors.New("unexpected end of expression")
+		}
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos)
+	}
+	return strconv.ParseFloat(p.input[start:p.pos], 64)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestToolRegistry_Register verifies name validation, duplicate rejection and ordering.
+func TestToolRegistry_Register(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	noop := func(context.Context, *Bot, int64, json.RawMessage) (string, error) { return "", nil }
+	r := newToolRegistry()
+
+	assert.NoError(t, r.Register(Tool{Name: "first", Run: noop}))
+	assert.NoError(t, r.Register(Tool{Name: "second", Run: noop}))
+	assert.Error(t, r.Register(Tool{Name: "first", Run: noop}), "duplicate name")
+	assert.Error(t, r.Register(Tool{Name: "has space", Run: noop}), "invalid name")
+	assert.Error(t, r.Register(Tool{Name: "no_run"}), "missing Run")
+
+	var names []string
+	for _, tool := range r.All() {
+		names = append(names, tool.Name)
+	}
+	assert.Equal(t, []string{"first", "second"}, names)
+}
+
+// TestEvaluateExpression verifies the calculator's precedence rules and error handling.
+func TestEvaluateExpression(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    float64
+		wantErr bool
+	}{
+		{"1 + 2 * 3", 7, false},
+		{"(1 + 2) * 3", 9, false},
+		{"2 ^ 3 ^ 2", 512, false},
+		{"-4 + 10 / 4", -1.5, false},
+		{"7 % 3", 1, false},
+		{"1 / 0", 0, true},
+		{"(1 + 2", 0, true},
+		{"2 +", 0, true},
+		{"abc", 0, true},
+		{"10 ^ 400", 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			got, err := evaluateExpression(tc.expr)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.InDelta(t, tc.want, got, 1e-9)
+		})
+	}
+}
+
+// TestCompleteWithTools verifies that tool calls are executed, their results are fed back to the
+// model, usage is summed across rounds and tools outside the user's scopes are refused.
+func TestCompleteWithTools(t *testing.T) {
+	b, _ := setupBotForTest(t, 123)
+	b.config.EnableTools = true
+	userID := int64(789)
+	_, err := b.getOrCreateUser(userID, "regular", false)
+	assert.NoError(t, err)
+
+	assert.NoError(t, b.tools.Register(Tool{
+		Name:  "restricted",
+		Scope: ScopeModelSet,
+		Run: func(context.Context, *Bot, int64, json.RawMessage) (string, error) {
+			t.Error("restricted tool must not run for a regular user")
+			return "", nil
+		},
+	}))
+
+	var requests []CompletionRequest
+	b.llm = &MockLLMProvider{CompleteFunc: func(_ context.Context, req CompletionRequest) (CompletionResponse, error) {
+		requests = append(requests, req)
+		if len(requests) == 1 {
+			return CompletionResponse{
+				ToolCalls: []ToolCall{
+					{ID: "call_1", Name: "calculate", Input: json.RawMessage(`{"expression":"6*7"}`)},
+					{ID: "call_2", Name: "restricted", Input: json.RawMessage(`{}`)},
+				},
+				Usage: Usage{InputTokens: 10, OutputTokens: 5},
+			}, nil
+		}
+		return CompletionResponse{Text: "The answer is 42.", Usage: Usage{InputTokens: 20, OutputTokens: 7}}, nil
+	}}
+
+	original := []anthropic.Message{anthropic.NewUserTextMessage("what is 6*7?")}
+	resp, err := b.completeWithTools(context.Background(), CompletionRequest{Messages: original}, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, "The answer is 42.", resp.Text)
+	assert.Equal(t, Usage{InputTokens: 30, OutputTokens: 12}, resp.Usage)
+	assert.Len(t, original, 1, "caller's messages must not be modified")
+
+	if !assert.Len(t, requests, 2) {
+		return
+	}
+	var offered []string
+	for _, def := range requests[0].Tools {
+		offered = append(offered, def.Name)
+	}
+	assert.Contains(t, offered, "calculate")
+	assert.NotContains(t, offered, "restricted")
+
+	followUp := requests[1].Messages
+	if assert.Len(t, followUp, 3) {
+		assert.Equal(t, anthropic.RoleAssistant, followUp[1].Role)
+		results := followUp[2].Content
+		if assert.Len(t, results, 2) {
+			assert.Equal(t, "42", results[0].MessageContentToolResult.Content[0].GetText())
+			assert.False(t, *results[0].MessageContentToolResult.IsError)
+			assert.Equal(t, "permission denied", results[1].MessageContentToolResult.Content[0].GetText())
+			assert.True(t, *results[1].MessageContentToolResult.IsError)
+		}
+	}
+}
+
+// TestCompleteWithTools_Disabled verifies that no tools are offered unless enable_tools is set.
+func TestCompleteWithTools_Disabled(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, _ := setupBotForTest(t, 123)
+	b.llm = &MockLLMProvider{CompleteFunc: func(_ context.Context, req CompletionRequest) (CompletionResponse, error) {
+		assert.Empty(t, req.Tools)
+		return CompletionResponse{Text: "hi"}, nil
+	}}
+
+	resp, err := b.completeWithTools(context.Background(), CompletionRequest{}, 123)
+	assert.NoError(t, err)
+	assert.Equal(t, "hi", resp.Text)
+}
+
+// TestCurrentTimeTool verifies that the time tool reads the bot clock and honours the timezone.
+func TestCurrentTimeTool(t *testing.T) {
+	b, _ := setupBotForTest(t, 123)
+	b.clock = &MockClock{currentTime: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+
+	got, err := runCurrentTimeTool(context.Background(), b, 123, json.RawMessage(`{"timezone":"Asia/Tokyo"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "Friday, 2024-03-01 21:00:00 JST", got)
+
+	_, err = runCurrentTimeTool(context.Background(), b, 123, json.RawMessage(`{"timezone":"Mars/Olympus"}`))
+	assert.Error(t, err)
+}
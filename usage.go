@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ModelPrice is the cost of a model in USD per million tokens.
+type ModelPrice struct {
+	Input      float64 `json:"input"`
+	Output     float64 `json:"output"`
+	CacheWrite float64 `json:"cache_write"`
+	CacheRead  float64 `json:"cache_read"`
+}
+
+// estimateCost prices usage with the configured price table. Models without a price cost 0.
+func (b *Bot) estimateCost(model string, usage Usage) float64 {
+	price, ok := b.config.Prices[model]
+	if !ok {
+		return 0
+	}
+	return (float64(usage.InputTokens)*price.Input +
+		float64(usage.OutputTokens)*price.Output +
+		float64(usage.CacheCreationInputTokens)*price.CacheWrite +
+		float64(usage.CacheReadInputTokens)*price.CacheRead) / 1e6
+}
+
+type usageKey struct{}
+
+// usageRecorder collects the model usage spent on answering one update, so it can be stored on
+// the assistant message that is sent for it. One update can call several models (e.g. the summary
+// on the bot's model and the reply on a persona's), so the cost is summed per call.
+type usageRecorder struct {
+	mu     sync.Mutex
+	userID int64
+	model  string // Model of the latest call, normally the one that wrote the reply
+	usage  Usage
+	cost   float64
+	used   bool
+}
+
+// withUsageRecorder returns a context in which recordUsage accumulates usage billed to userID.
+func withUsageRecorder(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, usageKey{}, &usageRecorder{userID: userID})
+}
+
+// recordUsage adds the usage of one completion call, priced at the rate of its model, to the
+// recorder of ctx, if there is one.
+func (b *Bot) recordUsage(ctx context.Context, model string, usage Usage) {
+	recorder, ok := ctx.Value(usageKey{}).(*usageRecorder)
+	if !ok {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.model = model
+	recorder.usage.Add(usage)
+	recorder.cost += b.estimateCost(model, usage)
+	recorder.used = true
+}
+
+// applyUsage moves the usage recorded in ctx onto the assistant message and resets the recorder,
+// so every completion is accounted exactly once.
+func (b *Bot) applyUsage(ctx context.Context, message *Message) {
+	recorder, ok := ctx.Value(usageKey{}).(*usageRecorder)
+	if !ok {
+		return
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if !recorder.used {
+		return
+	}
+	message.BilledUserID = recorder.userID
+	message.ModelName = recorder.model
+	message.InputTokens = recorder.usage.InputTokens
+	message.OutputTokens = recorder.usage.OutputTokens
+	message.CacheCreationTokens = recorder.usage.CacheCreationInputTokens
+	message.CacheReadTokens = recorder.usage.CacheReadInputTokens
+	message.Cost = recorder.cost
+	recorder.usage = Usage{}
+	recorder.cost = 0
+	recorder.used = false
+}
+
+// UsageTotals is the token usage and estimated spend summed over assistant messages.
+type UsageTotals struct {
+	InputTokens         int64
+	OutputTokens        int64
+	CacheCreationTokens int64
+	CacheReadTokens     int64
+	Cost                float64
+}
+
+// Tokens returns all tokens, cached ones included.
+func (t UsageTotals) Tokens() int64 {
+	return t.InputTokens + t.OutputTokens + t.CacheCreationTokens + t.CacheReadTokens
+}
+
+// getUsageTotals sums the usage of this bot since the given time (zero for all time), limited to
+// the replies billed to userID unless it is 0.
+func (b *Bot) getUsageTotals(userID int64, since time.Time) (UsageTotals, error) {
+	query := b.db.Model(&Message{}).
+		Select("COALESCE(SUM(input_tokens), 0) AS input_tokens, COALESCE(SUM(output_tokens), 0) AS output_tokens, "+
+			"COALESCE(SUM(cache_creation_tokens), 0) AS cache_creation_tokens, COALESCE(SUM(cache_read_tokens), 0) AS cache_read_tokens, "+
+			"COALESCE(SUM(cost), 0) AS cost").
+		Where("bot_id = ? AND is_user = ?", b.botID, false)
+	if userID != 0 {
+		query = query.Where("billed_user_id = ?", userID)
+	}
+	if !since.IsZero() {
+		query = query.Where("timestamp >= ?", since)
+	}
+
+	var totals UsageTotals
+	err := query.Scan(&totals).Error
+	return totals, err
+}
+
+// startOfDay returns midnight of the day of t.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// startOfMonth returns midnight of the first day of the month of t.
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// usageReport renders tokens and estimated spend for today, this month and all time.
+func (b *Bot) usageReport(userID int64) (string, error) {
+	now := b.clock.Now()
+	periods := []struct {
+		label string
+		since time.Time
+	}{
+		{"Today", startOfDay(now)},
+		{"This month", startOfMonth(now)},
+		{"All time", time.Time{}},
+	}
+
+	report := "💰 Usage:"
+	for _, period := range periods {
+		totals, err := b.getUsageTotals(userID, period.since)
+		if err != nil {
+			return "", err
+		}
+		report += fmt.Sprintf("\n- %s: %d tokens (%d in, %d out, %d cached), $%.4f",
+			period.label, totals.Tokens(), totals.InputTokens, totals.OutputTokens,
+			totals.CacheCreationTokens+totals.CacheReadTokens, totals.Cost)
+	}
+	return report, nil
+}
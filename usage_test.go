@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUsageAccounting verifies that the usage of a reply is stored on the assistant message,
+// priced with the configured table, and reported by /stats.
+func TestUsageAccounting(t *testing.T) {
+	b, mockTgClient := setupBotForTest(t, 123)
+	b.config.Prices = map[string]ModelPrice{
+		string(b.config.Model): {Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3},
+	}
+	b.llm = &MockLLMProvider{CompleteFunc: func(context.Context, CompletionRequest) (CompletionResponse, error) {
+		return CompletionResponse{Text: "reply", Usage: Usage{
+			InputTokens:              1000,
+			OutputTokens:             200,
+			CacheCreationInputTokens: 100,
+			CacheReadInputTokens:     1000,
+		}}, nil
+	}}
+	var lastSent string
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		lastSent = params.Text
+		return &models.Message{}, nil
+	}
+	send := func(userID int64, text string) {
+		b.handleUpdate(context.Background(), nil, commandUpdate(userID, userID, text))
+	}
+
+	send(789, "hello")
+	send(789, "again")
+
+	var reply Message
+	assert.NoError(t, b.db.Where("chat_id = ? AND is_user = ?", 789, false).Last(&reply).Error)
+	assert.Equal(t, int64(789), reply.BilledUserID)
+	assert.Equal(t, string(b.config.Model), reply.ModelName)
+	assert.Equal(t, 1000, reply.InputTokens)
+	assert.Equal(t, 200, reply.OutputTokens)
+	assert.Equal(t, 100, reply.CacheCreationTokens)
+	assert.Equal(t, 1000, reply.CacheReadTokens)
+	// 1000*3 + 200*15 + 100*3.75 + 1000*0.3 = 6675 USD per million tokens
+	assert.InDelta(t, 0.006675, reply.Cost, 1e-9)
+
+	totals, err := b.getUsageTotals(789, b.clock.Now().AddDate(0, 0, -1))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4600), totals.Tokens())
+	assert.InDelta(t, 0.01335, totals.Cost, 1e-9)
+
+	// Command replies are not model output and carry no usage.
+	send(789, "/stats user")
+	assert.Contains(t, lastSent, "- Today: 4600 tokens (2000 in, 400 out, 2200 cached), $0.0134")
+	var last Message
+	assert.NoError(t, b.db.Where("chat_id = ? AND is_user = ?", 789, false).Last(&last).Error)
+	assert.Zero(t, last.InputTokens)
+
+	// Bot-wide totals are only shown to users who may view any user's stats.
+	send(789, "/stats")
+	assert.NotContains(t, lastSent, "Usage:")
+	send(123, "/stats")
+	assert.Contains(t, lastSent, "- All time: 4600 tokens")
+}
+
+// TestRecordUsage_MixedModels verifies that calls to different models for one update are each
+// priced at their own model's rate.
+func TestRecordUsage_MixedModels(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, _ := setupBotForTest(t, 123)
+	b.config.Prices = map[string]ModelPrice{
+		"cheap":  {Input: 1, Output: 2},
+		"pricey": {Input: 10, Output: 20},
+	}
+	ctx := withUsageRecorder(context.Background(), 789)
+	b.recordUsage(ctx, "cheap", Usage{InputTokens: 1_000_000, OutputTokens: 1_000_000}) // summary: $3
+	b.recordUsage(ctx, "pricey", Usage{InputTokens: 100_000, OutputTokens: 100_000})    // reply: $3
+
+	var message Message
+	b.applyUsage(ctx, &message)
+	assert.InDelta(t, 6.0, message.Cost, 1e-9)
+	assert.Equal(t, "pricey", message.ModelName)
+	assert.Equal(t, 1_100_000, message.InputTokens)
+
+	var next Message
+	b.applyUsage(ctx, &next)
+	assert.Zero(t, next.Cost, "usage is applied once")
+}
@@ -32,7 +32,7 @@ func TestOwnerAssignment(t *testing.T) {
 	}
 
 	// Migrate the schema
-	err = db.AutoMigrate(&BotModel{}, &ConfigModel{}, &Message{}, &User{}, &Role{}, &Scope{})
+	err = db.AutoMigrate(&BotModel{}, &ConfigModel{}, &Message{}, &User{}, &Role{}, &Scope{}, &GroupSettings{}, &ChatSummary{}, &RateLimitState{}, &Ban{}, &AuditEntry{}, &AccessEntry{}, &InviteCode{}, &ChatPersona{}, &UserInstructions{})
 	if err != nil {
 		t.Fatalf(errMigrateSchema, err)
 	}
@@ -137,7 +137,7 @@ func TestPromoteUserToAdmin(t *testing.T) {
 	}
 
 	// Migrate the schema
-	err = db.AutoMigrate(&BotModel{}, &ConfigModel{}, &Message{}, &User{}, &Role{}, &Scope{})
+	err = db.AutoMigrate(&BotModel{}, &ConfigModel{}, &Message{}, &User{}, &Role{}, &Scope{}, &GroupSettings{}, &ChatSummary{}, &RateLimitState{}, &Ban{}, &AuditEntry{}, &AccessEntry{}, &InviteCode{}, &ChatPersona{}, &UserInstructions{})
 	if err != nil {
 		t.Fatalf(errMigrateSchema, err)
 	}
@@ -213,7 +213,7 @@ func TestGetOrCreateUser(t *testing.T) {
 	}
 
 	// Migrate the schema
-	err = db.AutoMigrate(&BotModel{}, &ConfigModel{}, &Message{}, &User{}, &Role{}, &Scope{})
+	err = db.AutoMigrate(&BotModel{}, &ConfigModel{}, &Message{}, &User{}, &Role{}, &Scope{}, &GroupSettings{}, &ChatSummary{}, &RateLimitState{}, &Ban{}, &AuditEntry{}, &AccessEntry{}, &InviteCode{}, &ChatPersona{}, &UserInstructions{})
 	if err != nil {
 		t.Fatalf(errMigrateSchema, err)
 	}
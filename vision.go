@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	tgbot "github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/liushuangls/go-anthropic/v2"
+)
+
+// defaultMaxImageSize matches the per-image limit of the Anthropic API (5 MB).
+const defaultMaxImageSize = 5 * 1024 * 1024
+
+// stickerCacheSize bounds how many sticker images are kept; popular stickers are reused across chats.
+const stickerCacheSize = 128
+
+// imagePlaceholder is stored as the message text when an image arrives without a caption.
+const imagePlaceholder = "[Image]"
+
+// errUnusableImage is returned by downloadImage for files that are too large or not a supported
+// still image; retrying such a file cannot succeed.
+var errUnusableImage = errors.New("unusable image")
+
+// supportedImageTypes are the media types accepted as image content blocks.
+var supportedImageTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// imageAttachment describes the image carried by a Telegram message.
+type imageAttachment struct {
+	FileID    string
+	MediaType string
+	Size      int64 // As reported by Telegram; 0 when unknown
+}
+
+// maxImageSize returns the configured per-image limit in bytes.
+func (b *Bot) maxImageSize() int64 {
+	if b.config.MaxImageSize > 0 {
+		return b.config.MaxImageSize
+	}
+	return defaultMaxImageSize
+}
+
+// imageFromMessage returns the image attached to a message, if any. For photos the largest size
+// within the per-bot limit is picked (falling back to the smallest one so it can be rejected);
+// documents are only considered when their MIME type is a supported image type.
+func (b *Bot) imageFromMessage(message *models.Message) (imageAttachment, bool) {
+	if len(message.Photo) > 0 {
+		// Telegram lists photo sizes from smallest to largest.
+		chosen := message.Photo[0]
+		for _, size := range message.Photo {
+			if int64(size.FileSize) <= b.maxImageSize() {
+				chosen = size
+			}
+		}
+		return imageAttachment{FileID: chosen.FileID, MediaType: "image/jpeg", Size: int64(chosen.FileSize)}, true
+	}
+	if doc := message.Document; doc != nil && supportedImageTypes[doc.MimeType] {
+		return imageAttachment{FileID: doc.FileID, MediaType: doc.MimeType, Size: doc.FileSize}, true
+	}
+	return imageAttachment{}, false
+}
+
+// downloadImage fetches a Telegram file and returns it base64-encoded, enforcing the size limit
+// even when Telegram did not report the size up front. The media type is sniffed from the content,
+// so files that are not a supported still image (animated or video stickers) are rejected.
+func (b *Bot) downloadImage(ctx context.Context, fileID string) (cachedImage, error) {
+	fileInfo, err := b.tgBot.GetFile(ctx, &tgbot.GetFileParams{FileID: fileID})
+	if err != nil {
+		return cachedImage{}, fmt.Errorf("telegram GetFile error: %w", err)
+	}
+	limit := b.maxImageSize()
+	if fileInfo.FileSize > limit {
+		return cachedImage{}, fmt.Errorf("%w: %d bytes, limit is %d", errUnusableImage, fileInfo.FileSize, limit)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.tgBot.FileDownloadLink(fileInfo), nil)
+	if err != nil {
+		return cachedImage{}, fmt.Errorf("image request error: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return cachedImage{}, fmt.Errorf("image download error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return cachedImage{}, fmt.Errorf("image download error: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return cachedImage{}, fmt.Errorf("image read error: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return cachedImage{}, fmt.Errorf("%w: exceeds limit of %d bytes", errUnusableImage, limit)
+	}
+	mediaType := http.DetectContentType(data)
+	if !supportedImageTypes[mediaType] {
+		return cachedImage{}, fmt.Errorf("%w: unsupported type %s", errUnusableImage, mediaType)
+	}
+	return cachedImage{Data: base64.StdEncoding.EncodeToString(data), MediaType: mediaType}, nil
+}
+
+// cachedImage is a downloaded image ready to be sent as a base64 content block.
+// An empty Data records that the file has no usable image, so it is not fetched again.
+type cachedImage struct {
+	Data      string
+	MediaType string
+}
+
+// imageCache keeps downloaded images keyed by Telegram file ID, so an image is not re-downloaded
+// on every turn. Entries are evicted oldest-first beyond capacity.
+type imageCache struct {
+	mu       sync.Mutex
+	entries  map[string]cachedImage
+	order    []string
+	capacity int
+}
+
+func newImageCache(capacity int) *imageCache {
+	return &imageCache{entries: make(map[string]cachedImage), capacity: capacity}
+}
+
+func (c *imageCache) get(fileID string) (cachedImage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	image, ok := c.entries[fileID]
+	return image, ok
+}
+
+func (c *imageCache) put(fileID string, image cachedImage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[fileID]; exists {
+		return
+	}
+	c.entries[fileID] = image
+	c.order = append(c.order, fileID)
+	for len(c.order) > c.capacity {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// loadContextImages downloads the photos and stickers referenced by the chat memory that are not
+// cached yet; stickers only for senders with vision:use. Failures are logged and the affected turns fall back to their text; photos that are
+// too large or not a usable image are cached as empty so they are not fetched again.
+func (b *Bot) loadContextImages(ctx context.Context, chatMemory *ChatMemory) {
+	b.chatMemoriesMu.RLock()
+	var photos, stickers []Message
+	for _, msg := range chatMemory.Messages {
+		if msg.ImageFileID != "" {
+			photos = append(photos, msg)
+		} else if msg.StickerFileID != "" {
+			stickers = append(stickers, msg)
+		}
+	}
+	b.chatMemoriesMu.RUnlock()
+
+	for _, msg := range photos {
+		if _, ok := b.images.get(msg.ImageFileID); ok {
+			continue
+		}
+		image, err := b.downloadImage(ctx, msg.ImageFileID)
+		if err != nil {
+			ErrorLogger.Printf("Error downloading image %s: %v", msg.ImageFileID, err)
+			if errors.Is(err, errUnusableImage) {
+				b.images.put(msg.ImageFileID, cachedImage{})
+			}
+			continue
+		}
+		b.images.put(msg.ImageFileID, image)
+	}
+	for _, msg := range stickers {
+		if b.hasScope(msg.UserID, ScopeVisionUse) {
+			b.loadStickerImage(ctx, msg)
+		}
+	}
+}
+
+// loadStickerImage caches the image of a sticker under its StickerFileID. Static stickers are used
+// as-is; for animated and video stickers (whose file is not a still image) the thumbnail is used.
+// Stickers without any usable image are cached as empty so they are not fetched again; transient
+// download failures are not cached.
+func (b *Bot) loadStickerImage(ctx context.Context, msg Message) {
+	if _, ok := b.stickers.get(msg.StickerFileID); ok {
+		return
+	}
+	transient := false
+	for _, fileID := range []string{msg.StickerFileID, msg.StickerPNGFile} {
+		if fileID == "" {
+			continue
+		}
+		image, err := b.downloadImage(ctx, fileID)
+		if err != nil {
+			if !errors.Is(err, errUnusableImage) {
+				ErrorLogger.Printf("Error downloading sticker file %s: %v", fileID, err)
+				transient = true
+			}
+			continue
+		}
+		b.stickers.put(msg.StickerFileID, image)
+		return
+	}
+	if !transient {
+		b.stickers.put(msg.StickerFileID, cachedImage{})
+	}
+}
+
+// imageContent returns the image block for a stored photo or sticker message, or false when no
+// image is available. Sticker images are shared across chats, so they are only used for senders
+// with vision:use; others' stickers stay emoji-only.
+func (b *Bot) imageContent(msg Message) (anthropic.MessageContent, bool) {
+	var image cachedImage
+	switch {
+	case msg.ImageFileID != "" && b.images != nil:
+		image, _ = b.images.get(msg.ImageFileID)
+	case msg.StickerFileID != "" && b.stickers != nil && b.hasScope(msg.UserID, ScopeVisionUse):
+		image, _ = b.stickers.get(msg.StickerFileID)
+	}
+	if image.Data == "" {
+		return anthropic.MessageContent{}, false
+	}
+	return anthropic.NewImageMessageContent(
+		anthropic.NewMessageContentSource(anthropic.MessagesContentSourceTypeBase64, image.MediaType, image.Data),
+	), true
+}
+
+// dropImage removes the image reference from a stored message (DB and chat memory), keeping the
+// text so the turn still makes sense in context.
+func (b *Bot) dropImage(chatID int64, userMsg Message) {
+	if err := b.db.Model(&userMsg).Updates(map[string]any{"image_file_id": "", "image_media_type": ""}).Error; err != nil {
+		ErrorLogger.Printf("Error removing image from message %d: %v", userMsg.ID, err)
+	}
+	b.chatMemoriesMu.Lock()
+	if mem, exists := b.chatMemories[chatID]; exists {
+		for i := len(mem.Messages) - 1; i >= 0; i-- {
+			if mem.Messages[i].ID == userMsg.ID {
+				mem.Messages[i].ImageFileID = ""
+				mem.Messages[i].ImageMediaType = ""
+				break
+			}
+		}
+	}
+	b.chatMemoriesMu.Unlock()
+}
+
+// handleImageMessage answers a photo or image document. Users without vision:use, or images over
+// the size limit, get a short refusal and the image is not kept in context (screenIncomingMessage
+// doesn't store the images of users without vision:use).
+func (b *Bot) handleImageMessage(ctx context.Context, image imageAttachment, userMsg Message, chatID, userID int64, username, firstName, lastName string, isPremium bool, languageCode string, messageTime int, isNewChat, isOwner bool, businessConnectionID string) {
+	if !b.hasScope(userID, ScopeVisionUse) {
+		if err := b.sendResponse(ctx, chatID, "You don't have permission to send images.", businessConnectionID); err != nil {
+			ErrorLogger.Printf("Error sending permission denied message: %v", err)
+		}
+		return
+	}
+
+	if limit := b.maxImageSize(); image.Size > limit {
+		b.dropImage(chatID, userMsg)
+		msg := fmt.Sprintf("That image is too large. The maximum size is %s.", formatBytes(limit))
+		if err := b.sendResponse(ctx, chatID, msg, businessConnectionID); err != nil {
+			ErrorLogger.Printf("Error sending image size message: %v", err)
+		}
+		return
+	}
+
+	contextMessages := b.buildContext(ctx, chatID)
+
+	response, err := b.getAnthropicResponse(ctx, chatID, userID, contextMessages, isNewChat, isOwner, false, username, firstName, lastName, isPremium, languageCode, messageTime)
+	if err != nil {
+		ErrorLogger.Printf("Error getting Anthropic response for image: %v", err)
+		response = b.anthropicErrorResponse(err, userID)
+	}
+
+	if err := b.sendResponse(ctx, chatID, response, businessConnectionID); err != nil {
+		ErrorLogger.Printf("Error sending response: %v", err)
+	}
+}
+
+// formatBytes renders a byte count in the largest whole unit, e.g. "5 MB".
+func formatBytes(n int64) string {
+	units := []string{"B", "KB", "MB", "GB"}
+	value := float64(n)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.1f", value), "0"), ".") + " " + units[i]
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/liushuangls/go-anthropic/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestImageFromMessage verifies photo size selection against the limit and image document detection.
+func TestImageFromMessage(t *testing.T) {
+	b, _ := setupBotForTest(t, 123)
+	b.config.MaxImageSize = 1000
+
+	photo := &models.Message{Photo: []models.PhotoSize{
+		{FileID: "small", FileSize: 100},
+		{FileID: "medium", FileSize: 900},
+		{FileID: "large", FileSize: 5000},
+	}}
+	image, ok := b.imageFromMessage(photo)
+	assert.True(t, ok)
+	assert.Equal(t, imageAttachment{FileID: "medium", MediaType: "image/jpeg", Size: 900}, image)
+
+	tooLarge := &models.Message{Photo: []models.PhotoSize{{FileID: "huge", FileSize: 2000}}}
+	image, ok = b.imageFromMessage(tooLarge)
+	assert.True(t, ok)
+	assert.Equal(t, "huge", image.FileID, "falls back to the smallest size so the caller can reject it")
+
+	png := &models.Message{Document: &models.Document{FileID: "doc", MimeType: "image/png", FileSize: 10}}
+	image, ok = b.imageFromMessage(png)
+	assert.True(t, ok)
+	assert.Equal(t, "image/png", image.MediaType)
+
+	_, ok = b.imageFromMessage(&models.Message{Document: &models.Document{FileID: "pdf", MimeType: "application/pdf"}})
+	assert.False(t, ok)
+	_, ok = b.imageFromMessage(&models.Message{Text: "hi"})
+	assert.False(t, ok)
+}
+
+// TestHandleUpdate_Photo verifies that a permitted photo is downloaded and sent as an image block
+// with its caption, and that the image is re-included in context on the next text message.
+func TestHandleUpdate_Photo(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	const ownerID int64 = 123
+	b, mockTgClient := setupBotForTest(t, ownerID)
+
+	imageBytes := []byte("\xff\xd8\xff\xe0fake-jpeg-body")
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		_, _ = w.Write(imageBytes)
+	}))
+	defer server.Close()
+
+	mockTgClient.GetFileFunc = func(ctx context.Context, params *bot.GetFileParams) (*models.File, error) {
+		assert.Equal(t, "photo-large", params.FileID)
+		return &models.File{FileID: params.FileID, FilePath: "photos/1.jpg"}, nil
+	}
+	mockTgClient.FileDownloadLinkFunc = func(f *models.File) string { return server.URL + "/" + f.FilePath }
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		return &models.Message{}, nil
+	}
+
+	var requests []CompletionRequest
+	b.llm = &MockLLMProvider{CompleteFunc: func(_ context.Context, req CompletionRequest) (CompletionResponse, error) {
+		requests = append(requests, req)
+		return CompletionResponse{Text: "A cat."}, nil
+	}}
+
+	b.handleUpdate(context.Background(), nil, &models.Update{Message: &models.Message{
+		Chat:    models.Chat{ID: ownerID},
+		From:    &models.User{ID: ownerID, Username: "owner"},
+		Caption: "What is this?",
+		Photo:   []models.PhotoSize{{FileID: "photo-small", FileSize: 10}, {FileID: "photo-large", FileSize: 100}},
+	}})
+	b.handleUpdate(context.Background(), nil, &models.Update{Message: &models.Message{
+		Chat: models.Chat{ID: ownerID},
+		From: &models.User{ID: ownerID, Username: "owner"},
+		Text: "Are you sure?",
+	}})
+
+	if !assert.Len(t, requests, 2) {
+		return
+	}
+	first := requests[0].Messages[0].Content
+	if assert.Len(t, first, 2) {
+		assert.Equal(t, anthropic.MessagesContentTypeImage, first[0].Type)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(imageBytes), first[0].Source.Data)
+		assert.Equal(t, "What is this?", *first[1].Text)
+	}
+	assert.Len(t, requests[1].Messages[0].Content, 2, "image stays in context while within memory_size")
+	assert.Equal(t, 1, downloads, "image is downloaded once and then served from the cache")
+
+	var stored Message
+	assert.NoError(t, b.db.Where("chat_id = ? AND is_user = ?", ownerID, true).First(&stored).Error)
+	assert.Equal(t, "photo-large", stored.ImageFileID)
+	assert.Equal(t, "What is this?", stored.Text)
+}
+
+// TestHandleUpdate_PhotoDenied verifies that users without vision:use and oversized images are
+// refused without calling the model, and the image reference is dropped.
+func TestHandleUpdate_PhotoDenied(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	tests := []struct {
+		name    string
+		userID  int64
+		size    int
+		wantMsg string
+	}{
+		{"no vision scope", 789, 10, "You don't have permission to send images."},
+		{"too large", 123, 2 * 1024 * 1024, "That image is too large. The maximum size is 1 MB."},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b, mockTgClient := setupBotForTest(t, 123)
+			b.config.MaxImageSize = 1024 * 1024
+			b.llm = &MockLLMProvider{CompleteFunc: func(context.Context, CompletionRequest) (CompletionResponse, error) {
+				t.Error("model must not be called")
+				return CompletionResponse{}, nil
+			}}
+
+			var sent string
+			mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+				sent = params.Text
+				return &models.Message{}, nil
+			}
+
+			b.handleUpdate(context.Background(), nil, &models.Update{Message: &models.Message{
+				Chat:  models.Chat{ID: tc.userID},
+				From:  &models.User{ID: tc.userID},
+				Photo: []models.PhotoSize{{FileID: "photo", FileSize: tc.size}},
+			}})
+
+			assert.Equal(t, tc.wantMsg, sent)
+			var stored Message
+			assert.NoError(t, b.db.Where("chat_id = ? AND is_user = ?", tc.userID, true).First(&stored).Error)
+			assert.Empty(t, stored.ImageFileID)
+			assert.Equal(t, imagePlaceholder, stored.Text)
+		})
+	}
+}
+
+// TestHandleUpdate_PhotoNotKept verifies that photos the bot doesn't answer are only kept for users
+// with vision:use, and that an unusable photo in context is fetched only once.
+func TestHandleUpdate_PhotoNotKept(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, mockTgClient := setupBotForTest(t, 123)
+	b.config.MaxImageSize = 1024
+	var fetched []string
+	mockTgClient.GetFileFunc = func(ctx context.Context, params *bot.GetFileParams) (*models.File, error) {
+		fetched = append(fetched, params.FileID)
+		return &models.File{FileID: params.FileID, FileSize: 4096}, nil
+	}
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		return &models.Message{}, nil
+	}
+	b.llm = &MockLLMProvider{CompleteFunc: func(context.Context, CompletionRequest) (CompletionResponse, error) {
+		return CompletionResponse{Text: "ok"}, nil
+	}}
+
+	// A group photo that doesn't address the bot is stored without its image.
+	photo := groupMessage("")
+	photo.Photo = []models.PhotoSize{{FileID: "group-photo", FileSize: 10}}
+	b.handleUpdate(context.Background(), nil, &models.Update{Message: photo})
+	var stored Message
+	assert.NoError(t, b.db.Where("chat_id = ? AND is_user = ?", testGroupID, true).First(&stored).Error)
+	assert.Empty(t, stored.ImageFileID)
+
+	// Telegram reports a larger file than the photo size; it is cached as unusable.
+	for _, update := range []*models.Message{
+		{Chat: models.Chat{ID: 123}, From: &models.User{ID: 123}, Photo: []models.PhotoSize{{FileID: "owner-photo", FileSize: 10}}},
+		{Chat: models.Chat{ID: 123}, From: &models.User{ID: 123}, Text: "Hello?"},
+	} {
+		b.handleUpdate(context.Background(), nil, &models.Update{Message: update})
+	}
+	assert.Equal(t, []string{"owner-photo"}, fetched)
+}
+
+// TestToOpenAIMessages_Image verifies that base64 image blocks become data-URL content parts.
+func TestToOpenAIMessages_Image(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	msgs := toOpenAIMessages("", []anthropic.Message{{
+		Role: anthropic.RoleUser,
+		Content: []anthropic.MessageContent{
+			anthropic.NewImageMessageContent(anthropic.NewMessageContentSource(anthropic.MessagesContentSourceTypeBase64, "image/png", "QUJD")),
+			anthropic.NewTextMessageContent("describe"),
+		},
+	}})
+
+	assert.Equal(t, []openAIChatMessage{{
+		Role: "user",
+		Content: []openAIContentPart{
+			{Type: "image_url", ImageURL: &openAIImageURL{URL: "data:image/png;base64,QUJD"}},
+			{Type: "text", Text: "describe"},
+		},
+	}}, msgs)
+}
+
+// TestHandleUpdate_StickerVision verifies that an animated sticker is shown to the model via its
+// thumbnail, that the image is cached by StickerFileID across messages, and that users without
+// vision:use get the emoji-only reply.
+func TestHandleUpdate_StickerVision(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, mockTgClient := setupBotForTest(t, 123)
+
+	files := map[string][]byte{
+		"animated": []byte("\x1f\x8b\x08\x00gzipped-lottie"),
+		"thumb":    []byte("RIFF\x00\x00\x00\x00WEBPVP8 thumbnail"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(files[r.URL.Path[1:]])
+	}))
+	defer server.Close()
+
+	var fetched []string
+	mockTgClient.GetFileFunc = func(ctx context.Context, params *bot.GetFileParams) (*models.File, error) {
+		fetched = append(fetched, params.FileID)
+		return &models.File{FileID: params.FileID, FilePath: params.FileID}, nil
+	}
+	mockTgClient.FileDownloadLinkFunc = func(f *models.File) string { return server.URL + "/" + f.FilePath }
+	mockTgClient.SendMessageFunc = func(ctx context.Context, params *bot.SendMessageParams) (*models.Message, error) {
+		return &models.Message{}, nil
+	}
+
+	var requests []CompletionRequest
+	b.llm = &MockLLMProvider{CompleteFunc: func(_ context.Context, req CompletionRequest) (CompletionResponse, error) {
+		requests = append(requests, req)
+		return CompletionResponse{Text: "Cute dancing cat!"}, nil
+	}}
+
+	b.config.SystemPrompts = map[string]string{"respond_with_emojis": "Reply with emojis."}
+	sticker := &models.Sticker{FileID: "animated", Emoji: "🐱", IsAnimated: true, Thumbnail: &models.PhotoSize{FileID: "thumb"}}
+	for _, userID := range []int64{123, 123, 789} {
+		b.handleUpdate(context.Background(), nil, &models.Update{Message: &models.Message{
+			Chat:    models.Chat{ID: userID},
+			From:    &models.User{ID: userID},
+			Sticker: sticker,
+		}})
+	}
+
+	assert.Equal(t, []string{"animated", "thumb"}, fetched, "sticker is fetched once, falling back to its thumbnail")
+	if assert.Len(t, requests, 3) {
+		last := requests[1].Messages[len(requests[1].Messages)-1].Content
+		if assert.Len(t, last, 2) {
+			assert.Equal(t, "image/webp", last[0].Source.MediaType)
+			assert.Equal(t, "Sent a sticker: 🐱", *last[1].Text)
+		}
+		assert.NotContains(t, requests[1].System, "Reply with emojis.")
+
+		// The cached image is not shown for a user without vision:use.
+		last = requests[2].Messages[len(requests[2].Messages)-1].Content
+		if assert.Len(t, last, 1) {
+			assert.Equal(t, "Sent a sticker: 🐱", *last[0].Text)
+		}
+		assert.Contains(t, requests[2].System, "Reply with emojis.")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram/bot"
+)
+
+// Transport identifiers accepted in the "transport" config field.
+const (
+	TransportPolling = "polling"
+	TransportWebhook = "webhook"
+)
+
+// telegramSecretHeader carries the secret_token given to setWebhook on every update.
+// see: https://core.telegram.org/bots/api#setwebhook
+const telegramSecretHeader = "X-Telegram-Bot-Api-Secret-Token" //nolint:gosec // header name, not a credential
+
+// webhookServer is an HTTP server shared by every webhook-mode bot that listens on the same
+// address. Updates are routed to the right bot by request path.
+type webhookServer struct {
+	addr  string
+	mux   *http.ServeMux
+	mu    sync.Mutex
+	paths map[string]bool
+}
+
+func newWebhookServer(addr string) *webhookServer {
+	return &webhookServer{
+		addr:  addr,
+		mux:   http.NewServeMux(),
+		paths: make(map[string]bool),
+	}
+}
+
+// Handle routes POST requests on path to handler after verifying the secret header.
+// Registering the same path twice is an error rather than a panic.
+func (s *webhookServer) Handle(path, secret string, handler http.Handler) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paths[path] {
+		return fmt.Errorf("webhook path %s is already registered on %s", path, s.addr)
+	}
+	s.paths[path] = true
+	s.mux.Handle("POST "+path, requireWebhookSecret(secret, handler))
+	return nil
+}
+
+// Run serves until ctx is cancelled, then shuts the server down gracefully.
+func (s *webhookServer) Run(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	InfoLogger.Printf("Webhook server listening on %s", s.addr)
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+// requireWebhookSecret rejects requests whose secret header does not match. An empty secret
+// rejects every request; validateTransport requires one for webhook bots.
+func requireWebhookSecret(secret string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get(telegramSecretHeader)
+		if secret == "" || subtle.ConstantTimeCompare([]byte(got), []byte(secret)) != 1 {
+			InfoLogger.Printf("Rejected webhook request on %s: invalid secret token", r.URL.Path)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// webhookPath returns the configured webhook path, defaulting to "/<bot id>".
+func (c BotConfig) webhookPath() string {
+	if c.WebhookPath != "" {
+		return c.WebhookPath
+	}
+	return "/" + c.ID
+}
+
+// webhookURL is the public URL Telegram posts updates to.
+func (c BotConfig) webhookURL() string {
+	return strings.TrimRight(c.WebhookPublicURL, "/") + c.webhookPath()
+}
+
+// registerWebhook mounts this bot's update handler on the shared server.
+func (b *Bot) registerWebhook(srv *webhookServer) error {
+	return srv.Handle(b.config.webhookPath(), b.config.WebhookSecretToken, b.tgBot.WebhookHandler())
+}
+
+// runWebhook points Telegram at the bot's webhook URL, processes updates until ctx is
+// cancelled, and removes the webhook again on shutdown.
+func (b *Bot) runWebhook(ctx context.Context) {
+	_, err := b.tgBot.SetWebhook(ctx, &bot.SetWebhookParams{
+		URL:         b.config.webhookURL(),
+		SecretToken: b.config.WebhookSecretToken,
+	})
+	if err != nil {
+		ErrorLogger.Printf("[%s] Error setting webhook: %v", b.config.ID, err)
+		return
+	}
+	InfoLogger.Printf("[%s] Webhook set to %s", b.config.ID, b.config.webhookURL())
+
+	b.tgBot.StartWebhook(ctx)
+
+	// ctx is already cancelled here, so use a fresh one for the cleanup call.
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := b.tgBot.DeleteWebhook(cleanupCtx, &bot.DeleteWebhookParams{}); err != nil {
+		ErrorLogger.Printf("[%s] Error deleting webhook: %v", b.config.ID, err)
+		return
+	}
+	InfoLogger.Printf("[%s] Webhook deleted", b.config.ID)
+}
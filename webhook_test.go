@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram/bot"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWebhookServer_Routing verifies that updates are routed by path, that the secret header
+// is enforced per bot, and that a path cannot be registered twice.
+func TestWebhookServer_Routing(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	srv := newWebhookServer(":0")
+
+	var hits []string
+	handlerFor := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits = append(hits, name)
+		})
+	}
+	assert.NoError(t, srv.Handle("/alpha", "s3cret", handlerFor("alpha")))
+	assert.NoError(t, srv.Handle("/beta", "b3ta", handlerFor("beta")))
+	assert.NoError(t, srv.Handle("/gamma", "", handlerFor("gamma")))
+	assert.Error(t, srv.Handle("/alpha", "other", handlerFor("dupe")))
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		secret     string
+		wantStatus int
+	}{
+		{"alpha with valid secret", http.MethodPost, "/alpha", "s3cret", http.StatusOK},
+		{"alpha with wrong secret", http.MethodPost, "/alpha", "nope", http.StatusUnauthorized},
+		{"alpha without secret", http.MethodPost, "/alpha", "", http.StatusUnauthorized},
+		{"beta with its own secret", http.MethodPost, "/beta", "b3ta", http.StatusOK},
+		{"beta with alpha's secret", http.MethodPost, "/beta", "s3cret", http.StatusUnauthorized},
+		{"gamma without secret configured", http.MethodPost, "/gamma", "", http.StatusUnauthorized},
+		{"unknown path", http.MethodPost, "/delta", "", http.StatusNotFound},
+		{"GET is not allowed", http.MethodGet, "/beta", "b3ta", http.StatusMethodNotAllowed},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader("{}"))
+			if tc.secret != "" {
+				req.Header.Set(telegramSecretHeader, tc.secret)
+			}
+			rec := httptest.NewRecorder()
+			srv.mux.ServeHTTP(rec, req)
+			assert.Equal(t, tc.wantStatus, rec.Code)
+		})
+	}
+
+	assert.Equal(t, []string{"alpha", "beta"}, hits)
+}
+
+// TestBotStart_Webhook verifies that webhook mode registers the webhook with the public URL and
+// secret on start and deletes it once the context is cancelled.
+func TestBotStart_Webhook(t *testing.T) { //NOSONAR go:S100 -- underscore separation is idiomatic in Go test names
+	b, mockTgClient := setupBotForTest(t, 123)
+	b.config.Transport = TransportWebhook
+	b.config.WebhookListenAddr = ":8443"
+	b.config.WebhookPublicURL = "https://bots.example.com/"
+	b.config.WebhookSecretToken = "abc_123"
+
+	var setParams *bot.SetWebhookParams
+	deleted := false
+	mockTgClient.SetWebhookFunc = func(ctx context.Context, params *bot.SetWebhookParams) (bool, error) {
+		setParams = params
+		return true, nil
+	}
+	mockTgClient.DeleteWebhookFunc = func(ctx context.Context, params *bot.DeleteWebhookParams) (bool, error) {
+		deleted = true
+		return true, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockTgClient.StartWebhookFunc = func(ctx context.Context) {
+		assert.False(t, deleted, "webhook must not be deleted while running")
+		cancel()
+		<-ctx.Done()
+	}
+
+	b.Start(ctx)
+
+	if assert.NotNil(t, setParams) {
+		assert.Equal(t, "https://bots.example.com/test_bot", setParams.URL)
+		assert.Equal(t, "abc_123", setParams.SecretToken)
+	}
+	assert.True(t, deleted)
+}
+
+// TestValidateTransport verifies webhook configuration checks.
+func TestValidateTransport(t *testing.T) {
+	valid := BotConfig{
+		ID:                 "bot",
+		Transport:          TransportWebhook,
+		WebhookListenAddr:  ":8080",
+		WebhookPublicURL:   "https://bots.example.com",
+		WebhookSecretToken: "abc_123",
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(c *BotConfig)
+		wantErr string
+	}{
+		{"polling default", func(c *BotConfig) { *c = BotConfig{} }, ""},
+		{"valid webhook", func(c *BotConfig) {}, ""},
+		{"unknown transport", func(c *BotConfig) { c.Transport = "carrier-pigeon" }, "unknown 'transport'"},
+		{"missing listen addr", func(c *BotConfig) { c.WebhookListenAddr = "" }, "webhook_listen_addr"},
+		{"http public url", func(c *BotConfig) { c.WebhookPublicURL = "http://bots.example.com" }, "https"},
+		{"relative path", func(c *BotConfig) { c.WebhookPath = "hook" }, "webhook_path"},
+		{"missing secret", func(c *BotConfig) { c.WebhookSecretToken = "" }, "webhook_secret_token"},
+		{"invalid secret", func(c *BotConfig) { c.WebhookSecretToken = "has space" }, "webhook_secret_token"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := valid
+			tc.mutate(&cfg)
+			err := validateTransport(&cfg)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.wantErr)
+			}
+		})
+	}
+}